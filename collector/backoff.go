@@ -0,0 +1,193 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"log/slog"
+	"sync"
+	"time"
+
+	kingpin "github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var (
+	backoffThreshold = kingpin.Flag("lmutil.backoff-threshold",
+		"Consecutive failures of a license before lmutil calls are skipped with an exponential backoff. Use 0 to disable.").
+		Default("3").Int()
+	backoffInitial = kingpin.Flag("lmutil.backoff-initial",
+		"Initial backoff of a failing license.").Default("30s").Duration()
+	backoffMax = kingpin.Flag("lmutil.backoff-max",
+		"Maximum backoff of a failing license.").Default("10m").Duration()
+
+	errBackoff = errors.New("license skipped after consecutive failures")
+
+	backoffOpenDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "backoff_open"),
+		"flexlm_exporter: Whether the lmutil calls of a license are skipped after consecutive failures.",
+		[]string{collectorString, nameString},
+		nil,
+	)
+	backoffFailuresDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "consecutive_failures"),
+		"flexlm_exporter: Number of consecutive failures of a license scrape.",
+		[]string{collectorString, nameString},
+		nil,
+	)
+	backoffSecondsDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "backoff_seconds"),
+		"flexlm_exporter: Current backoff of a failing license, 0 when the license doesn't fail.",
+		[]string{collectorString, nameString},
+		nil,
+	)
+
+	// scrapeBackoff keeps the consecutive failures of the licenses of all the
+	// collectors.
+	scrapeBackoff = newBackoffTracker()
+)
+
+// breakerKey identifies a license of a collector.
+type breakerKey struct {
+	collector string
+	name      string
+}
+
+// breaker is the backoff state of a license. It opens after threshold
+// consecutive failures, until the backoff elapsed. A single trial call is
+// then allowed, which closes the breaker again on success, or opens it with
+// a doubled backoff on failure.
+type breaker struct {
+	failures  int
+	backoff   time.Duration
+	openUntil time.Time
+	trial     bool
+}
+
+type backoffTracker struct {
+	mtx      sync.Mutex
+	breakers map[breakerKey]*breaker
+}
+
+func newBackoffTracker() *backoffTracker {
+	return &backoffTracker{breakers: make(map[breakerKey]*breaker)}
+}
+
+// allow returns whether lmutil can be called for a license.
+func (t *backoffTracker) allow(key breakerKey, threshold int, now time.Time) bool {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	b, ok := t.breakers[key]
+	if !ok || threshold <= 0 || b.failures < threshold {
+		return true
+	}
+
+	if now.Before(b.openUntil) || b.trial {
+		return false
+	}
+
+	b.trial = true
+
+	return true
+}
+
+// done records the result of the lmutil calls of a license, and returns the
+// new backoff of the license, 0 if the breaker is closed.
+func (t *backoffTracker) done(key breakerKey, err error, threshold int, initial, maxBackoff time.Duration,
+	now time.Time) time.Duration {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	b, ok := t.breakers[key]
+	if !ok {
+		b = &breaker{}
+		t.breakers[key] = b
+	}
+
+	b.trial = false
+
+	if err == nil {
+		*b = breaker{}
+		return 0
+	}
+
+	b.failures++
+	if threshold <= 0 || b.failures < threshold {
+		return 0
+	}
+
+	if b.backoff == 0 {
+		b.backoff = initial
+	} else {
+		b.backoff = min(2*b.backoff, maxBackoff)
+	}
+
+	b.openUntil = now.Add(b.backoff)
+
+	return b.backoff
+}
+
+// withBackoff calls the lmutil collect function of a license, unless the
+// license is in backoff after consecutive failures, and logs its error. Only
+// the lmutil errors are failures, the configuration and parse errors don't
+// depend on the license server.
+func withBackoff(collector, app string, logger *slog.Logger, collect func() error) error {
+	key := breakerKey{collector: collector, name: app}
+	if !scrapeBackoff.allow(key, *backoffThreshold, time.Now()) {
+		logger.Debug("license skipped after consecutive failures", "app", app)
+
+		return errBackoff
+	}
+
+	err := collect()
+	if err != nil {
+		logger.Error("couldn't scrape license", "app", app, "err", err)
+	}
+
+	var lmutilErr *lmutilError
+
+	breakerErr := err
+	if !errors.As(err, &lmutilErr) {
+		breakerErr = nil
+	}
+
+	backoff := scrapeBackoff.done(key, breakerErr, *backoffThreshold, *backoffInitial, *backoffMax, time.Now())
+	if backoff > 0 {
+		logger.Warn("skipping license after consecutive failures", "app", app, "backoff", backoff)
+	}
+
+	return err
+}
+
+// collect sends the backoff state of the licenses.
+func (t *backoffTracker) collect(ch chan<- prometheus.Metric, now time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for key, b := range t.breakers {
+		open := 0.0
+		if now.Before(b.openUntil) {
+			open = 1.0
+		}
+
+		ch <- prometheus.MustNewConstMetric(backoffOpenDesc, prometheus.GaugeValue, open, key.collector, key.name)
+
+		ch <- prometheus.MustNewConstMetric(backoffFailuresDesc, prometheus.GaugeValue, float64(b.failures),
+			key.collector, key.name)
+
+		ch <- prometheus.MustNewConstMetric(backoffSecondsDesc, prometheus.GaugeValue, b.backoff.Seconds(),
+			key.collector, key.name)
+	}
+}
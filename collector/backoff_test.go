@@ -0,0 +1,218 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestBackoffTracker(t *testing.T) {
+	t.Parallel()
+
+	const (
+		threshold = 2
+		initial   = 30 * time.Second
+		maxDelay  = time.Minute
+	)
+
+	tracker := newBackoffTracker()
+	key := breakerKey{collector: "lmstat", name: "app1"}
+	errDown := errors.New("server down")
+	now := time.Now()
+
+	fail := func(want time.Duration) {
+		t.Helper()
+
+		if !tracker.allow(key, threshold, now) {
+			t.Fatal("want lmutil call allowed")
+		}
+
+		if backoff := tracker.done(key, errDown, threshold, initial, maxDelay, now); backoff != want {
+			t.Fatalf("want %s backoff, got %s", want, backoff)
+		}
+	}
+
+	// The breaker opens after threshold consecutive failures.
+	fail(0)
+	fail(initial)
+
+	if tracker.allow(key, threshold, now.Add(initial-time.Second)) {
+		t.Fatal("want lmutil call skipped during the backoff")
+	}
+
+	// A single trial call is allowed once the backoff elapsed.
+	now = now.Add(initial)
+
+	if !tracker.allow(key, threshold, now) || tracker.allow(key, threshold, now) {
+		t.Fatal("want a single trial call")
+	}
+
+	// The trial call fails, the backoff doubles up to the maximum.
+	tracker.done(key, errDown, threshold, initial, maxDelay, now)
+
+	now = now.Add(time.Minute)
+	fail(maxDelay)
+
+	// A successful trial call closes the breaker.
+	now = now.Add(time.Minute)
+
+	if !tracker.allow(key, threshold, now) {
+		t.Fatal("want a trial call")
+	}
+
+	if backoff := tracker.done(key, nil, threshold, initial, maxDelay, now); backoff != 0 {
+		t.Fatalf("want breaker closed, got %s backoff", backoff)
+	}
+
+	fail(0)
+
+	// Without threshold, lmutil is always called.
+	key = breakerKey{collector: "lmstat", name: "app2"}
+
+	for range 5 {
+		if !tracker.allow(key, 0, now) || tracker.done(key, errDown, 0, initial, maxDelay, now) != 0 {
+			t.Fatal("want lmutil call allowed without threshold")
+		}
+	}
+}
+
+func TestWithBackoff(t *testing.T) {
+	logger := promslog.New(&promslog.Config{})
+
+	threshold, initial, maxBackoff := *backoffThreshold, *backoffInitial, *backoffMax
+	*backoffThreshold, *backoffInitial, *backoffMax = 2, time.Minute, time.Minute
+
+	defer func() { *backoffThreshold, *backoffInitial, *backoffMax = threshold, initial, maxBackoff }()
+
+	// Parse and configuration errors don't open the breaker.
+	errParse := errors.New("couldn't parse")
+	for range 3 {
+		if err := withBackoff("test_parse", "app1", logger, func() error { return errParse }); !errors.Is(err, errParse) {
+			t.Fatalf("want parse error, got %v", err)
+		}
+	}
+
+	// lmutil errors do.
+	errLmutil := newLmutilError([]string{"lmstat", "-a"}, errors.New("exit status 1"))
+	for range 2 {
+		if err := withBackoff("test_lmutil", "app1", logger, func() error { return errLmutil }); !errors.Is(err, errLmutil) {
+			t.Fatalf("want lmutil error, got %v", err)
+		}
+	}
+
+	err := withBackoff("test_lmutil", "app1", logger, func() error { return nil })
+	if !errors.Is(err, errBackoff) {
+		t.Fatalf("want backoff, got %v", err)
+	}
+
+	// The scrape error of a skipped scrape has the backoff reason.
+	for _, tc := range []struct {
+		err    error
+		value  float64
+		reason string
+	}{
+		{err, 1, scrapeErrorBackoff},
+		{errLmutil, 1, scrapeErrorError},
+		{nil, 0, ""},
+	} {
+		ch := make(chan prometheus.Metric, 10)
+		sendLicenseScrape(ch, "test_reason", "app1", time.Now(), tc.err)
+		close(ch)
+
+		found := 0
+
+		for m := range ch {
+			if m.Desc() != scrapeErrorDesc {
+				continue
+			}
+
+			var metric dto.Metric
+			if err := m.Write(&metric); err != nil {
+				t.Fatal(err)
+			}
+
+			labels := make(map[string]string)
+			for _, l := range metric.GetLabel() {
+				labels[l.GetName()] = l.GetValue()
+			}
+
+			if metric.GetGauge().GetValue() != tc.value || labels["reason"] != tc.reason || labels[nameString] != "app1" {
+				t.Fatalf("%v: unexpected scrape error %v", tc.err, &metric)
+			}
+
+			found++
+		}
+
+		if found != 1 {
+			t.Fatalf("%v: want 1 scrape error, got %d", tc.err, found)
+		}
+	}
+}
+
+func TestWithBackoffLogs(t *testing.T) {
+	threshold := *backoffThreshold
+	*backoffThreshold = 0
+
+	defer func() { *backoffThreshold = threshold }()
+
+	var logs bytes.Buffer
+
+	logger := slog.New(slog.NewJSONHandler(&logs, nil)).With(collectorString, "test_log")
+
+	// Errors that don't come from lmutil are logged too.
+	errConfig := errors.New("couldn't replay 'lmstat -a': no such file")
+	if err := withBackoff("test_log", "app1", logger, func() error { return errConfig }); !errors.Is(err, errConfig) {
+		t.Fatalf("want config error, got %v", err)
+	}
+
+	if err := withBackoff("test_log", "app1", logger, func() error { return nil }); err != nil {
+		t.Fatal(err)
+	}
+
+	var entries []map[string]any
+
+	for line := range strings.SplitSeq(strings.TrimSpace(logs.String()), "\n") {
+		var entry map[string]any
+		if err := json.Unmarshal([]byte(line), &entry); err != nil {
+			t.Fatal(err)
+		}
+
+		entries = append(entries, entry)
+	}
+
+	if len(entries) != 1 {
+		t.Fatalf("want 1 log entry, got %v", entries)
+	}
+
+	for key, want := range map[string]string{
+		"level":         "ERROR",
+		appString:       "app1",
+		collectorString: "test_log",
+		"err":           errConfig.Error(),
+	} {
+		if entries[0][key] != want {
+			t.Errorf("%s: want %q, got %v", key, want, entries[0][key])
+		}
+	}
+}
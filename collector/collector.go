@@ -16,6 +16,7 @@
 package collector
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"log/slog"
@@ -31,6 +32,7 @@ const (
 	// Namespace defines the common namespace to be used by all metrics.
 	namespace       = "flexlm"
 	defaultEnabled  = true
+	defaultDisabled = false
 	appString       = "app"
 	collectorString = "collector"
 	nameString      = "name"
@@ -53,8 +55,15 @@ var (
 	)
 	scrapeErrorDesc = prometheus.NewDesc(
 		prometheus.BuildFQName(namespace, "scrape", "error"),
-		"flexlm_exporter: Whether a license scrape had an error.",
-		[]string{collectorString, nameString},
+		"flexlm_exporter: Whether a license scrape had an error, with the reason backoff when lmutil wasn't called "+
+			"after consecutive failures, error otherwise, and empty without error.",
+		[]string{collectorString, nameString, "reason"},
+		nil,
+	)
+	unparsedLinesDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "lmstat", "unparsed_lines"),
+		"flexlm_exporter: Number of lmstat output lines of the feature or expiration section that were not recognized.",
+		[]string{appString, collectorString},
 		nil,
 	)
 )
@@ -150,6 +159,22 @@ func NewFlexlmCollector(logger *slog.Logger, filters ...string) (*FlexlmCollecto
 	return &FlexlmCollector{Collectors: collectors, logger: logger}, nil
 }
 
+// poller is implemented by the collectors that also poll lmutil in the
+// background between two scrapes.
+type poller interface {
+	pollLicenses(ctx context.Context)
+}
+
+// StartPolling starts the background polling of the collectors until ctx is
+// canceled. Only the serve command polls, the one-shot and push runs don't.
+func (n FlexlmCollector) StartPolling(ctx context.Context) {
+	for _, c := range n.Collectors {
+		if p, ok := c.(poller); ok {
+			go p.pollLicenses(ctx)
+		}
+	}
+}
+
 // Describe implements the prometheus.Collector interface.
 func (n FlexlmCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- scrapeDurationDesc
@@ -157,6 +182,8 @@ func (n FlexlmCollector) Describe(ch chan<- *prometheus.Desc) {
 	ch <- scrapeSuccessDesc
 
 	ch <- scrapeErrorDesc
+
+	ch <- unparsedLinesDesc
 }
 
 // Collect implements the prometheus.Collector interface.
@@ -173,6 +200,33 @@ func (n FlexlmCollector) Collect(ch chan<- prometheus.Metric) {
 	}
 
 	wg.Wait()
+
+	lmutilSlots.collect(ch)
+	collectLmutil(ch)
+	scrapeBackoff.collect(ch, time.Now())
+}
+
+// sendLicenseScrape sends the duration of the scrape of a license started at
+// begin, whether it had an error and why, and its latest error and last
+// success.
+func sendLicenseScrape(ch chan<- prometheus.Metric, collector, name string, begin time.Time, err error) {
+	ch <- prometheus.MustNewConstMetric(scrapeLicenseDurationDesc, prometheus.GaugeValue, time.Since(begin).Seconds(),
+		collector, name)
+
+	key := breakerKey{collector: collector, name: name}
+	scrapeStatus.observe(key, err, time.Now())
+	scrapeStatus.send(ch, key)
+
+	scrapeError, reason := 0.0, ""
+
+	switch {
+	case errors.Is(err, errBackoff):
+		scrapeError, reason = 1, scrapeErrorBackoff
+	case err != nil:
+		scrapeError, reason = 1, scrapeErrorError
+	}
+
+	ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, scrapeError, collector, name, reason)
 }
 
 func execute(name string, c Collector, ch chan<- prometheus.Metric, logger *slog.Logger) {
@@ -0,0 +1,392 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjtrangoni/flexlm_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	debugLogOut         = "OUT"
+	debugLogIn          = "IN"
+	debugLogDenied      = "DENIED"
+	debugLogUnsupported = "UNSUPPORTED"
+	reasonUnknown       = "unknown"
+
+	daemonEventStart          = "start"
+	daemonEventShutdown       = "shutdown"
+	daemonEventReread         = "reread"
+	daemonEventLostConnection = "lost_connection"
+	daemonEventUnknown        = ""
+)
+
+type debugLogCollector struct {
+	denials   *prometheus.CounterVec
+	checkouts *prometheus.CounterVec
+	checkins  *prometheus.CounterVec
+	events    *prometheus.CounterVec
+	lastEvent *prometheus.GaugeVec
+	mtx       sync.Mutex
+	files     map[string]*debugLogFile
+	logger    *slog.Logger
+}
+
+// debugLogFile follows a debug log file across rotations and truncations.
+type debugLogFile struct {
+	path    string
+	file    *os.File
+	partial []byte
+	// date is the last date seen in the log file, as the lines only have
+	// the time of the day.
+	date time.Time
+}
+
+// debugLogEvent is a usage line of a debug log.
+type debugLogEvent struct {
+	vendor  string
+	event   string
+	feature string
+	reason  string
+}
+
+func init() {
+	registerCollector("debug_log", defaultDisabled, NewDebugLogCollector)
+}
+
+// NewDebugLogCollector returns a new Collector exposing the license usage
+// found in the lmgrd and vendor daemon debug logs.
+func NewDebugLogCollector(logger *slog.Logger) (Collector, error) {
+	return &debugLogCollector{
+		denials: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "feature",
+			Name:      "denials_total",
+			Help:      "License feature denials found in the debug log labeled by app, feature name and reason.",
+		}, []string{appString, nameString, "reason"}),
+		checkouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "feature",
+			Name:      "log_checkouts_total",
+			Help:      "License feature checkouts found in the debug log labeled by app and feature name.",
+		}, []string{appString, nameString}),
+		checkins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "feature",
+			Name:      "log_checkins_total",
+			Help:      "License feature check-ins found in the debug log labeled by app and feature name.",
+		}, []string{appString, nameString}),
+		events: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "vendor",
+			Name:      "events_total",
+			Help: "License daemon start, shutdown, reread and lost connection events found in the debug log " +
+				"labeled by app, daemon name and event.",
+		}, []string{appString, nameString, "event"}),
+		lastEvent: prometheus.NewGaugeVec(prometheus.GaugeOpts{
+			Namespace: namespace,
+			Subsystem: "vendor",
+			Name:      "last_event_timestamp_seconds",
+			Help:      "Timestamp of the last license daemon event found in the debug log labeled by app, daemon name and event.",
+		}, []string{appString, nameString, "event"}),
+		files:  make(map[string]*debugLogFile),
+		logger: logger,
+	}, nil
+}
+
+// Update reads the lines appended to the debug logs since the last scrape.
+func (c *debugLogCollector) Update(ch chan<- prometheus.Metric) error {
+	c.mtx.Lock()
+	defer c.mtx.Unlock()
+
+	for _, licenses := range LicenseConfig.Licenses {
+		if licenses.DebugLog == "" {
+			continue
+		}
+
+		begin := time.Now()
+		err := c.collect(&licenses)
+		if err != nil {
+			c.logger.Error("couldn't read debug log", "app", licenses.Name, "err", err)
+		}
+
+		sendLicenseScrape(ch, "debug_log", licenses.Name, begin, err)
+	}
+
+	c.denials.Collect(ch)
+	c.checkouts.Collect(ch)
+	c.checkins.Collect(ch)
+	c.events.Collect(ch)
+	c.lastEvent.Collect(ch)
+
+	return nil
+}
+
+// debugLogPaths returns the paths of a comma separated debug_log value,
+// without surrounding spaces and empty entries.
+func debugLogPaths(value string) []string {
+	var paths []string
+
+	for path := range strings.SplitSeq(value, ",") {
+		if path = strings.TrimSpace(path); path != "" {
+			paths = append(paths, path)
+		}
+	}
+
+	return paths
+}
+
+// collect reads the new lines of the debug logs of a license. A log that can't
+// be read doesn't stop the others.
+func (c *debugLogCollector) collect(licenses *config.License) error {
+	var errs []error
+
+	for _, path := range debugLogPaths(licenses.DebugLog) {
+		key := licenses.Name + "\x00" + path
+
+		logFile, ok := c.files[key]
+		if !ok {
+			logFile = &debugLogFile{path: filepath.Clean(path)}
+			c.files[key] = logFile
+		}
+
+		lines, err := logFile.readLines()
+		if err != nil {
+			errs = append(errs, err)
+			continue
+		}
+
+		for _, line := range lines {
+			if daemon, event, at, ok := logFile.parseDaemonLine(line); ok {
+				c.events.WithLabelValues(licenses.Name, daemon, event).Inc()
+				c.lastEvent.WithLabelValues(licenses.Name, daemon, event).Set(float64(at.Unix()))
+
+				continue
+			}
+
+			event, ok := parseDebugLogLine(line)
+			if !ok {
+				continue
+			}
+
+			switch event.event {
+			case debugLogOut:
+				c.checkouts.WithLabelValues(licenses.Name, event.feature).Inc()
+			case debugLogIn:
+				c.checkins.WithLabelValues(licenses.Name, event.feature).Inc()
+			case debugLogDenied, debugLogUnsupported:
+				c.denials.WithLabelValues(licenses.Name, event.feature, event.reason).Inc()
+			}
+		}
+	}
+
+	return errors.Join(errs...)
+}
+
+// readLines returns the complete lines appended to the log file since the
+// last call. The first call only moves to the end of the file, so the history
+// is not counted again after a restart of the exporter. A rotated log file is
+// read until its end before following the new one, and a truncated log file,
+// e.g. after a restart of lmgrd, is read again from its beginning.
+func (f *debugLogFile) readLines() ([]string, error) {
+	if f.file == nil {
+		file, err := os.Open(f.path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open debug log %s: %w", f.path, err)
+		}
+
+		if _, err := file.Seek(0, io.SeekEnd); err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("couldn't seek debug log %s: %w", f.path, err)
+		}
+
+		f.file = file
+
+		return nil, nil
+	}
+
+	out, err := io.ReadAll(f.file)
+	if err != nil {
+		return nil, fmt.Errorf("couldn't read debug log %s: %w", f.path, err)
+	}
+
+	current, err := f.file.Stat()
+	if err != nil {
+		return nil, fmt.Errorf("couldn't stat debug log %s: %w", f.path, err)
+	}
+
+	latest, err := os.Stat(f.path)
+
+	switch {
+	case err != nil:
+		// The log file was moved away, and the new one is not created yet.
+	case !os.SameFile(current, latest):
+		file, err := os.Open(f.path)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't open debug log %s: %w", f.path, err)
+		}
+
+		rotated, err := io.ReadAll(file)
+		if err != nil {
+			_ = file.Close()
+			return nil, fmt.Errorf("couldn't read debug log %s: %w", f.path, err)
+		}
+
+		_ = f.file.Close()
+		f.file = file
+
+		// Terminate the last line of the rotated file.
+		if out = append(f.partial, out...); len(out) > 0 && out[len(out)-1] != '\n' {
+			out = append(out, '\n')
+		}
+
+		f.partial = nil
+		out = append(out, rotated...)
+	default:
+		offset, err := f.file.Seek(0, io.SeekCurrent)
+		if err != nil {
+			return nil, fmt.Errorf("couldn't seek debug log %s: %w", f.path, err)
+		}
+
+		if latest.Size() < offset {
+			if _, err := f.file.Seek(0, io.SeekStart); err != nil {
+				return nil, fmt.Errorf("couldn't seek debug log %s: %w", f.path, err)
+			}
+
+			f.partial = nil
+
+			truncated, err := io.ReadAll(f.file)
+			if err != nil {
+				return nil, fmt.Errorf("couldn't read debug log %s: %w", f.path, err)
+			}
+
+			out = truncated
+		}
+	}
+
+	data := append(f.partial, out...)
+	end := bytes.LastIndexByte(data, '\n')
+	f.partial = append([]byte(nil), data[end+1:]...)
+
+	if end < 0 {
+		return nil, nil
+	}
+
+	return strings.Split(string(data[:end]), "\n"), nil
+}
+
+// parseDebugLogLine parses an OUT, IN, DENIED or UNSUPPORTED line of a debug
+// log.
+func parseDebugLogLine(line string) (debugLogEvent, bool) {
+	line = strings.TrimRight(line, "\r ")
+	if !debugLogUsageRegex.MatchString(line) {
+		return debugLogEvent{}, false
+	}
+
+	matches := reSubMatchMap(debugLogUsageRegex, line)
+	event := debugLogEvent{
+		vendor:  matches["vendor"],
+		event:   matches["event"],
+		feature: matches["feature"],
+	}
+
+	if event.event == debugLogDenied || event.event == debugLogUnsupported {
+		event.reason = reasonUnknown
+		if debugLogReasonRegex.MatchString(matches["rest"]) {
+			reason := reSubMatchMap(debugLogReasonRegex, matches["rest"])["reason"]
+			event.reason = strings.TrimSuffix(strings.TrimSpace(reason), ".")
+		} else if event.event == debugLogUnsupported {
+			event.reason = strings.ToLower(debugLogUnsupported)
+		}
+	}
+
+	return event, true
+}
+
+// parseDaemonLine parses the start, shutdown, reread and lost connection
+// lines of a debug log, and returns the daemon name, the event and its time.
+// The date lines are only used to keep track of the current date.
+func (f *debugLogFile) parseDaemonLine(line string) (daemon, event string, at time.Time, ok bool) {
+	line = strings.TrimRight(line, "\r ")
+	if !debugLogDaemonRegex.MatchString(line) {
+		return "", "", time.Time{}, false
+	}
+
+	matches := reSubMatchMap(debugLogDaemonRegex, line)
+	message := matches["message"]
+
+	if debugLogDateRegex.MatchString(message) {
+		date, err := time.ParseInLocation("1/2/2006", reSubMatchMap(debugLogDateRegex, message)["date"], time.Local)
+		if err == nil {
+			f.date = date
+		}
+	}
+
+	switch {
+	case debugLogUsageRegex.MatchString(line):
+		event = daemonEventUnknown
+	case debugLogStartRegex.MatchString(message):
+		event = daemonEventStart
+	case debugLogShutdownRegex.MatchString(message):
+		event = daemonEventShutdown
+	case debugLogLostConnectionRegex.MatchString(message):
+		event = daemonEventLostConnection
+	case debugLogRereadRegex.MatchString(message):
+		event = daemonEventReread
+	default:
+		event = daemonEventUnknown
+	}
+
+	if event == daemonEventUnknown {
+		return "", "", time.Time{}, false
+	}
+
+	return matches["daemon"], event, f.eventTime(matches["time"]), true
+}
+
+// eventTime returns the time of a debug log line, using the last date seen in
+// the log file, or the current date.
+func (f *debugLogFile) eventTime(clock string) time.Time {
+	now := time.Now()
+
+	date := f.date
+	if date.IsZero() {
+		date = time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	}
+
+	var hour, minute, second int
+	if _, err := fmt.Sscanf(clock, "%d:%d:%d", &hour, &minute, &second); err != nil {
+		return now
+	}
+
+	at := time.Date(date.Year(), date.Month(), date.Day(), hour, minute, second, 0, time.Local)
+	// The line was written before midnight, with the date of the last
+	// midnight.
+	if f.date.IsZero() && at.After(now) {
+		at = at.AddDate(0, 0, -1)
+	}
+
+	return at
+}
@@ -0,0 +1,231 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mjtrangoni/flexlm_exporter/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+const (
+	testDebugLog1 = "fixtures/lmgrd_app1.log"
+)
+
+func TestParseDebugLogLine(t *testing.T) {
+	t.Parallel()
+
+	dataByte, err := os.ReadFile(testDebugLog1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	events := map[string]int{}
+	reasons := map[string]int{}
+
+	for line := range strings.SplitSeq(string(dataByte), "\n") {
+		event, ok := parseDebugLogLine(line)
+		if !ok {
+			continue
+		}
+
+		events[event.event]++
+
+		if event.reason != "" {
+			reasons[event.feature+":"+event.reason]++
+		}
+	}
+
+	if events[debugLogOut] != 2 || events[debugLogIn] != 2 ||
+		events[debugLogDenied] != 3 || events[debugLogUnsupported] != 1 {
+		t.Fatalf("Unexpected events: %v", events)
+	}
+
+	if reasons["feature1:Licensed number of users already reached"] != 2 {
+		t.Fatalf("Unexpected denials for feature1: %v", reasons)
+	}
+
+	if reasons["feature9:License server system does not support this feature"] != 1 {
+		t.Fatalf("Unexpected denials for feature9: %v", reasons)
+	}
+
+	if reasons["feature2:User/host on EXCLUDE list for feature"] != 1 {
+		t.Fatalf("Unexpected denials for feature2: %v", reasons)
+	}
+}
+
+func TestDebugLogPaths(t *testing.T) {
+	t.Parallel()
+
+	paths := debugLogPaths(" a.log, b.log,,c.log ,")
+	if strings.Join(paths, "|") != "a.log|b.log|c.log" {
+		t.Fatalf("Unexpected debug log paths: %q", paths)
+	}
+
+	if paths := debugLogPaths(""); len(paths) != 0 {
+		t.Fatalf("Unexpected debug log paths: %q", paths)
+	}
+}
+
+func TestParseDaemonLine(t *testing.T) {
+	t.Parallel()
+
+	dataByte, err := os.ReadFile(testDebugLog1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logFile := &debugLogFile{}
+	events := map[string]int{}
+	last := map[string]time.Time{}
+
+	for line := range strings.SplitSeq(string(dataByte), "\n") {
+		daemon, event, at, ok := logFile.parseDaemonLine(line)
+		if !ok {
+			continue
+		}
+
+		events[daemon+":"+event]++
+		last[daemon+":"+event] = at
+	}
+
+	if events["lmgrd:start"] != 1 || events["VENDOR1:start"] != 2 || events["VENDOR1:reread"] != 1 ||
+		events["VENDOR1:shutdown"] != 1 || events["VENDOR1:lost_connection"] != 1 || len(events) != 5 {
+		t.Fatalf("Unexpected events: %v", events)
+	}
+
+	expected := time.Date(2017, 10, 20, 14, 45, 40, 0, time.Local)
+	if !last["VENDOR1:start"].Equal(expected) {
+		t.Fatalf("Unexpected last start of VENDOR1: %s != %s", last["VENDOR1:start"], expected)
+	}
+}
+
+func TestDebugLogFileReadLines(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "lmgrd.log")
+	if err := os.WriteFile(path, []byte("history line\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	logFile := &debugLogFile{path: path}
+
+	// The history is skipped on the first read.
+	lines, err := logFile.readLines()
+	if err != nil || len(lines) != 0 {
+		t.Fatalf("Unexpected lines on first read: %v, %v", lines, err)
+	}
+
+	appendLog := func(data string) {
+		f, err := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o600)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if _, err := f.WriteString(data); err != nil {
+			t.Fatal(err)
+		}
+
+		if err := f.Close(); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	appendLog("line1\nline")
+
+	lines, err = logFile.readLines()
+	if err != nil || len(lines) != 1 || lines[0] != "line1" {
+		t.Fatalf("Unexpected lines after append: %v, %v", lines, err)
+	}
+
+	// Incomplete lines are kept until they are terminated.
+	appendLog("2\n")
+
+	lines, err = logFile.readLines()
+	if err != nil || len(lines) != 1 || lines[0] != "line2" {
+		t.Fatalf("Unexpected lines after completion: %v, %v", lines, err)
+	}
+
+	// Rotation: the old file is read until its end before following the new one.
+	appendLog("line3\n")
+
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(path, []byte("line4\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err = logFile.readLines()
+	if err != nil || strings.Join(lines, ",") != "line3,line4" {
+		t.Fatalf("Unexpected lines after rotation: %v, %v", lines, err)
+	}
+
+	// Truncation: the file is read again from its beginning.
+	if err := os.WriteFile(path, []byte("l5\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	lines, err = logFile.readLines()
+	if err != nil || strings.Join(lines, ",") != "l5" {
+		t.Fatalf("Unexpected lines after truncation: %v, %v", lines, err)
+	}
+}
+
+func TestDebugLogCollectMissingPath(t *testing.T) {
+	t.Parallel()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "lmgrd.log")
+
+	if err := os.WriteFile(path, []byte("history line\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewDebugLogCollector(promslog.New(&promslog.Config{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collector, ok := c.(*debugLogCollector)
+	if !ok {
+		t.Fatalf("unexpected collector %T", c)
+	}
+
+	// The missing log comes first, and must not hide the next one.
+	license := &config.License{Name: "app1", DebugLog: filepath.Join(dir, "missing.log") + "," + path}
+
+	if err := collector.collect(license); err == nil {
+		t.Fatal("want error for the missing debug log")
+	}
+
+	if err := os.WriteFile(path, []byte("history line\n14:11:51 (VENDOR1) OUT: \"feature1\" user1@host1\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := collector.collect(license); err == nil {
+		t.Fatal("want error for the missing debug log")
+	}
+
+	if got := testutil.ToFloat64(collector.checkouts.WithLabelValues("app1", "feature1")); got != 1 {
+		t.Fatalf("want 1 checkout, got %v", got)
+	}
+}
@@ -1,5 +1,14 @@
 package collector
 
+import (
+	"errors"
+	"fmt"
+	"math"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
 // The original error codes are converted to unsigned integers,
 // e.g. -15 = 241 (-15 + 256).
 // Reference: http://www.opendtect.org/lic/doc/endusermanual/chap13.htm
@@ -132,3 +141,50 @@ var errorDescriptionString = map[string]string{
 	"exit status 129": "A hostid needed for the composite hostid is missing or invalid.",
 	"exit status 128": "Error, borrowed license doesn't match any known server license.",
 }
+
+// lmutilError is an lmutil execution failure, with the FLEXlm error code and
+// description of its exit status.
+type lmutilError struct {
+	command     string
+	code        string
+	description string
+	err         error
+}
+
+func newLmutilError(args []string, err error) *lmutilError {
+	e := &lmutilError{
+		command:     *lmutilPath + " " + strings.Join(args, " "),
+		code:        "exec",
+		description: errorDescriptionString[err.Error()],
+		err:         err,
+	}
+
+	if e.description == "" {
+		e.description = "unknown error"
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		e.code = strconv.Itoa(flexlmErrorCode(exitErr.ExitCode()))
+	}
+
+	return e
+}
+
+func (e *lmutilError) Error() string {
+	return fmt.Sprintf("error while calling '%s': %v:'%s'", e.command, e.err, e.description)
+}
+
+func (e *lmutilError) Unwrap() error {
+	return e.err
+}
+
+// flexlmErrorCode returns the original, negative, FLEXlm error code of an
+// lmutil exit status.
+func flexlmErrorCode(status int) int {
+	if status > math.MaxInt8 {
+		return status - math.MaxUint8 - 1
+	}
+
+	return status
+}
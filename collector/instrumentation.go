@@ -0,0 +1,111 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"os/exec"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	lmutilExecBucketStart  = 0.1
+	lmutilExecBucketFactor = 2
+	lmutilExecBucketCount  = 10
+
+	commandString = "command"
+)
+
+var (
+	lmutilExecDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "lmutil",
+		Name:      "exec_duration_seconds",
+		Help:      "flexlm_exporter: Duration of the lmutil executions labeled by app and command.",
+		Buckets:   prometheus.ExponentialBuckets(lmutilExecBucketStart, lmutilExecBucketFactor, lmutilExecBucketCount),
+	}, []string{appString, commandString})
+	lmutilExecutions = prometheus.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "lmutil",
+		Name:      "executions_total",
+		Help:      "flexlm_exporter: lmutil executions labeled by app, command and exit status.",
+	}, []string{appString, commandString, "status"})
+	lmutilOutputBytes = prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Namespace: namespace,
+		Subsystem: "lmutil",
+		Name:      "output_bytes",
+		Help:      "flexlm_exporter: Size of the output of the last lmutil execution labeled by app and command.",
+	}, []string{appString, commandString})
+
+	scrapeLicenseDurationDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "license_duration_seconds"),
+		"flexlm_exporter: Duration of a license scrape.",
+		[]string{collectorString, nameString},
+		nil,
+	)
+)
+
+// lmutilCommand returns the lmutil command without the license file or server
+// and the option values, e.g. "lmstat -f" for lmstat -c 27000@host -f feature1.
+func lmutilCommand(args []string) string {
+	command := make([]string, 0, len(args))
+
+	for i, arg := range args {
+		switch {
+		case i == 0:
+			command = append(command, arg)
+		case arg == "-c":
+		case strings.HasPrefix(arg, "-"):
+			command = append(command, arg)
+		}
+	}
+
+	return strings.Join(command, " ")
+}
+
+// lmutilExitStatus returns the exit status of an lmutil execution, or error
+// when it couldn't be started.
+func lmutilExitStatus(err error) string {
+	if err == nil {
+		return "0"
+	}
+
+	var exitErr *exec.ExitError
+	if errors.As(err, &exitErr) {
+		return strconv.Itoa(exitErr.ExitCode())
+	}
+
+	return "error"
+}
+
+// observeLmutil records the duration, exit status and output size of an
+// lmutil execution.
+func observeLmutil(app string, args []string, duration time.Duration, out []byte, err error) {
+	command := lmutilCommand(args)
+
+	lmutilExecDuration.WithLabelValues(app, command).Observe(duration.Seconds())
+	lmutilExecutions.WithLabelValues(app, command, lmutilExitStatus(err)).Inc()
+	lmutilOutputBytes.WithLabelValues(app, command).Set(float64(len(out)))
+}
+
+// collectLmutil sends the lmutil execution metrics.
+func collectLmutil(ch chan<- prometheus.Metric) {
+	lmutilExecDuration.Collect(ch)
+	lmutilExecutions.Collect(ch)
+	lmutilOutputBytes.Collect(ch)
+}
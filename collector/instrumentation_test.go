@@ -0,0 +1,52 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"os/exec"
+	"testing"
+)
+
+func TestLmutilCommand(t *testing.T) {
+	t.Parallel()
+
+	for want, args := range map[string][]string{
+		"lmstat -a": {"lmstat", "-c", "27000@host1", "-a"},
+		"lmstat -f": {"lmstat", "-c", "/etc/license.dat", "-f", "feature1"},
+		"lmstat -v": {"lmstat", "-v"},
+		"lmstat":    {"lmstat", "-c", "27000@host1"},
+	} {
+		if got := lmutilCommand(args); got != want {
+			t.Fatalf("%v: want %q, got %q", args, want, got)
+		}
+	}
+}
+
+func TestLmutilExitStatus(t *testing.T) {
+	t.Parallel()
+
+	if status := lmutilExitStatus(nil); status != "0" {
+		t.Fatalf("want 0, got %s", status)
+	}
+
+	err := exec.Command("sh", "-c", "exit 3").Run()
+	if status := lmutilExitStatus(err); status != "3" {
+		t.Fatalf("want 3, got %s (%v)", status, err)
+	}
+
+	if status := lmutilExitStatus(errors.New("exec: not found")); status != "error" {
+		t.Fatalf("want error, got %s", status)
+	}
+}
@@ -0,0 +1,450 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/mjtrangoni/flexlm_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	licenseLineServer    = "SERVER"
+	licenseLineVendor    = "VENDOR"
+	licenseLineDaemon    = "DAEMON"
+	licenseLineFeature   = "FEATURE"
+	licenseLineIncrement = "INCREMENT"
+	licenseLinePackage   = "PACKAGE"
+	licenseLineUpgrade   = "UPGRADE"
+
+	// Minimum number of positional fields of the license file lines.
+	featureFields = 6
+	upgradeFields = 7
+	packageFields = 3
+	serverFields  = 3
+	vendorFields  = 2
+)
+
+type licenseFileCollector struct {
+	licenseFeatureInfo       *prometheus.Desc
+	licenseFeatureCount      *prometheus.Desc
+	licenseFeatureExpiration *prometheus.Desc
+	licenseFeatureStart      *prometheus.Desc
+	licenseServerInfo        *prometheus.Desc
+	licenseVendorInfo        *prometheus.Desc
+	logger                   *slog.Logger
+}
+
+func init() {
+	registerCollector("license_file", defaultDisabled, NewLicenseFileCollector)
+}
+
+// NewLicenseFileCollector returns a new Collector exposing the license file
+// SERVER, VENDOR, FEATURE, INCREMENT and UPGRADE lines.
+func NewLicenseFileCollector(logger *slog.Logger) (Collector, error) {
+	featureLabels := []string{appString, nameString, "vendor", versionString, "expiry"}
+
+	return &licenseFileCollector{
+		licenseFeatureInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "license", "feature_info"),
+			"License file FEATURE, INCREMENT and UPGRADE lines labeled by app, name, vendor, version, "+
+				"expiry, type, start, vendor_info, issuer, sn, hostid, uncounted and node_locked.",
+			append(append([]string{}, featureLabels...),
+				"type", "start", "vendor_info", "issuer", "sn", "hostid", "uncounted", "node_locked"), nil,
+		),
+		licenseFeatureCount: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "license", "feature_count"),
+			"License file feature count labeled by app, name, vendor, version and expiry. Uncounted lines count 0.",
+			featureLabels, nil,
+		),
+		licenseFeatureExpiration: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "license", "feature_expiration_seconds"),
+			"License file feature expiration date in seconds labeled by app, name, vendor, version and expiry.",
+			featureLabels, nil,
+		),
+		licenseFeatureStart: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "license", "feature_start_seconds"),
+			"License file feature start date in seconds labeled by app, name, vendor, version and expiry.",
+			featureLabels, nil,
+		),
+		licenseServerInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "license", "server_info"),
+			"A metric with a constant '1' value labeled by app, host, hostid and port of the license file SERVER lines.",
+			[]string{appString, "host", "hostid", "port"}, nil,
+		),
+		licenseVendorInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "license", "vendor_info"),
+			"A metric with a constant '1' value labeled by app, name, path, port and options of the license file VENDOR lines.",
+			[]string{appString, nameString, "path", "port", "options"}, nil,
+		),
+		logger: logger,
+	}, nil
+}
+
+// Update parses the license files that are readable from the exporter.
+func (c *licenseFileCollector) Update(ch chan<- prometheus.Metric) error {
+	wg := &sync.WaitGroup{}
+	defer wg.Wait()
+
+	for _, licenses := range LicenseConfig.Licenses {
+		if !isLicenseFileReadable(licenses.LicenseFile) {
+			continue
+		}
+
+		wg.Add(lenghtOne)
+
+		go func(licenses config.License) {
+			defer wg.Done()
+
+			begin := time.Now()
+			err := c.collect(&licenses, ch)
+			if err != nil {
+				c.logger.Error("couldn't parse license file", "app", licenses.Name, "err", err)
+			}
+
+			sendLicenseScrape(ch, "license_file", licenses.Name, begin, err)
+		}(licenses)
+	}
+
+	return nil
+}
+
+func (c *licenseFileCollector) collect(licenses *config.License, ch chan<- prometheus.Metric) error {
+	lf, err := readLicenseFile(licenses.LicenseFile, c.logger)
+	if err != nil {
+		return err
+	}
+
+	featuresToExclude, featuresToInclude, err := featuresFilter(licenses)
+	if err != nil {
+		return err
+	}
+
+	for _, s := range lf.servers {
+		ch <- prometheus.MustNewConstMetric(c.licenseServerInfo, prometheus.GaugeValue, 1.0,
+			licenses.Name, s.host, s.hostid, s.port)
+	}
+
+	for _, v := range lf.vendors {
+		ch <- prometheus.MustNewConstMetric(c.licenseVendorInfo, prometheus.GaugeValue, 1.0,
+			licenses.Name, v.name, v.path, v.port, v.options)
+	}
+
+	// Several lines with the same name, vendor, version and expiry date are
+	// merged, so the series are not identified by the line number.
+	type featureInfoKey struct {
+		featureExpKey
+
+		lineType, start, vendorInfo, issuer, sn, hostid, uncounted, nodeLocked string
+	}
+
+	counts := make(map[featureExpKey]float64)
+	expirations := make(map[featureExpKey]float64)
+	starts := make(map[featureExpKey]float64)
+	infos := make(map[featureInfoKey]bool)
+
+	for _, f := range lf.features {
+		if contains(featuresToExclude, f.name) {
+			continue
+		} else if licenses.FeaturesToInclude != "" &&
+			!contains(featuresToInclude, f.name) {
+			continue
+		}
+
+		key := featureExpKey{name: f.name, vendor: f.vendor, version: f.version, expiry: dateLabel(f.expires)}
+		counts[key] += f.count
+		expirations[key] = f.expires
+
+		if f.start > 0 && (starts[key] == 0 || f.start < starts[key]) {
+			starts[key] = f.start
+		}
+
+		start := ""
+		if f.start > 0 {
+			start = dateLabel(f.start)
+		}
+
+		infos[featureInfoKey{
+			featureExpKey: key,
+			lineType:      f.lineType,
+			start:         start,
+			vendorInfo:    f.vendorInfo,
+			issuer:        f.issuer,
+			sn:            f.sn,
+			hostid:        f.hostid,
+			uncounted:     strconv.FormatBool(f.uncounted),
+			nodeLocked:    strconv.FormatBool(f.nodeLocked),
+		}] = true
+	}
+
+	for key, count := range counts {
+		ch <- prometheus.MustNewConstMetric(c.licenseFeatureCount, prometheus.GaugeValue, count,
+			licenses.Name, key.name, key.vendor, key.version, key.expiry)
+
+		ch <- prometheus.MustNewConstMetric(c.licenseFeatureExpiration, prometheus.GaugeValue, expirations[key],
+			licenses.Name, key.name, key.vendor, key.version, key.expiry)
+
+		if start, ok := starts[key]; ok {
+			ch <- prometheus.MustNewConstMetric(c.licenseFeatureStart, prometheus.GaugeValue, start,
+				licenses.Name, key.name, key.vendor, key.version, key.expiry)
+		}
+	}
+
+	for key := range infos {
+		ch <- prometheus.MustNewConstMetric(c.licenseFeatureInfo, prometheus.GaugeValue, 1.0,
+			licenses.Name, key.name, key.vendor, key.version, key.expiry,
+			key.lineType, key.start, key.vendorInfo, key.issuer, key.sn, key.hostid, key.uncounted, key.nodeLocked)
+	}
+
+	return nil
+}
+
+// isLicenseFileReadable returns whether license_file is a single regular
+// file, and not a port@host combination or a list of license files.
+func isLicenseFileReadable(path string) bool {
+	if path == "" {
+		return false
+	}
+
+	info, err := os.Stat(filepath.Clean(path))
+	if err != nil {
+		return false
+	}
+
+	return info.Mode().IsRegular()
+}
+
+// readLicenseFile reads and parses a license file.
+func readLicenseFile(path string, logger *slog.Logger) (*licenseFile, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open license file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	return parseLicenseFile(file, logger)
+}
+
+// parseLicenseFile parses the SERVER, VENDOR, FEATURE, INCREMENT, PACKAGE and
+// UPGRADE lines of a license file.
+func parseLicenseFile(r io.Reader, logger *slog.Logger) (*licenseFile, error) {
+	lf := &licenseFile{}
+
+	err := scanContinuedLines(r, func(line string) {
+		lf.parseLine(line, logger)
+	})
+	if err != nil {
+		return lf, fmt.Errorf("could not parse license file: %w", err)
+	}
+
+	return lf, nil
+}
+
+// scanContinuedLines calls fn for each line, skipping empty lines and
+// comments. Lines ending with a backslash are continued on the next line.
+func scanContinuedLines(r io.Reader, fn func(line string)) error {
+	scanner := bufio.NewScanner(r)
+	scanner.Split(bufio.ScanLines)
+
+	var continued strings.Builder
+
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if continued.Len() == 0 && (line == "" || strings.HasPrefix(line, "#")) {
+			continue
+		}
+
+		if before, ok := strings.CutSuffix(line, `\`); ok {
+			continued.WriteString(before)
+			continued.WriteString(" ")
+
+			continue
+		}
+
+		continued.WriteString(line)
+		fn(continued.String())
+		continued.Reset()
+	}
+
+	if continued.Len() > 0 {
+		fn(continued.String())
+	}
+
+	return scanner.Err()
+}
+
+func (lf *licenseFile) parseLine(line string, logger *slog.Logger) {
+	fields, attributes := splitLicenseLine(line)
+	if len(fields) == 0 {
+		return
+	}
+
+	switch strings.ToUpper(fields[0]) {
+	case licenseLineServer:
+		if len(fields) < serverFields {
+			logger.Debug("invalid license file SERVER line", "line", line)
+			return
+		}
+
+		s := &licenseServer{host: fields[1], hostid: fields[2]}
+		if len(fields) > serverFields {
+			s.port = fields[3]
+		}
+
+		lf.servers = append(lf.servers, s)
+	case licenseLineVendor, licenseLineDaemon:
+		if len(fields) < vendorFields {
+			logger.Debug("invalid license file VENDOR line", "line", line)
+			return
+		}
+
+		v := &licenseVendor{name: fields[1], port: attributes["PORT"], options: attributes["OPTIONS"]}
+		// The daemon path and the options file can be positional too.
+		if len(fields) > vendorFields {
+			v.path = fields[2]
+		}
+
+		if len(fields) > vendorFields+1 && v.options == "" {
+			v.options = fields[3]
+		}
+
+		lf.vendors = append(lf.vendors, v)
+	case licenseLineFeature, licenseLineIncrement:
+		if len(fields) < featureFields {
+			logger.Debug("invalid license file FEATURE line", "line", line)
+			return
+		}
+
+		lf.features = append(lf.features, newLicenseFeature(strings.ToUpper(fields[0]),
+			fields[1], fields[2], fields[3], fields[4], fields[5], attributes, logger))
+	case licenseLineUpgrade:
+		if len(fields) < upgradeFields {
+			logger.Debug("invalid license file UPGRADE line", "line", line)
+			return
+		}
+
+		// UPGRADE lines define the version the licenses are upgraded from,
+		// before the version they are upgraded to.
+		lf.features = append(lf.features, newLicenseFeature(licenseLineUpgrade,
+			fields[1], fields[2], fields[4], fields[5], fields[6], attributes, logger))
+	case licenseLinePackage:
+		if len(fields) < packageFields {
+			logger.Debug("invalid license file PACKAGE line", "line", line)
+			return
+		}
+
+		p := &licensePackage{name: fields[1], vendor: fields[2]}
+		if len(fields) > packageFields {
+			p.version = fields[3]
+		}
+
+		// Components are defined as name[:version[:count]].
+		for component := range strings.FieldsSeq(attributes["COMPONENTS"]) {
+			p.components = append(p.components, strings.Split(component, ":")[0])
+		}
+
+		lf.packages = append(lf.packages, p)
+	}
+}
+
+func newLicenseFeature(lineType, name, vendor, version, expiry, count string,
+	attributes map[string]string, logger *slog.Logger) *licenseFeature {
+	f := &licenseFeature{
+		lineType:   lineType,
+		name:       name,
+		vendor:     vendor,
+		version:    version,
+		expires:    parseExpirationDate(expiry, logger),
+		vendorInfo: attributes["VENDOR_INFO"],
+		issuer:     attributes["ISSUER"],
+		sn:         attributes["SN"],
+		hostid:     attributes["HOSTID"],
+	}
+
+	if start, ok := attributes["START"]; ok {
+		if f.start = parseExpirationDate(start, logger); math.IsInf(f.start, posInfinity) {
+			f.start = 0
+		}
+	}
+
+	if strings.EqualFold(count, "uncounted") || count == "0" {
+		f.uncounted = true
+	} else if n, err := strconv.Atoi(count); err == nil {
+		f.count = float64(n)
+	} else {
+		logger.Error("err", "could not convert", count, "to integer:", err)
+	}
+
+	hostid := strings.ToUpper(f.hostid)
+	f.nodeLocked = hostid != "" && hostid != "ANY" && hostid != "DEMO"
+
+	return f
+}
+
+// splitLicenseLine splits a license file line into its positional fields and
+// its keyword=value attributes. Quoted values may contain spaces.
+func splitLicenseLine(line string) (fields []string, attributes map[string]string) {
+	attributes = make(map[string]string)
+
+	for _, t := range splitQuoted(line) {
+		if key, value, ok := strings.Cut(t, "="); ok {
+			attributes[strings.ToUpper(key)] = value
+		} else {
+			fields = append(fields, t)
+		}
+	}
+
+	return fields, attributes
+}
+
+// splitQuoted splits a line on spaces and tabs, except within double quotes.
+// The quotes are removed.
+func splitQuoted(line string) []string {
+	var (
+		token   strings.Builder
+		inQuote bool
+		tokens  []string
+	)
+
+	for _, r := range line {
+		switch {
+		case r == '"':
+			inQuote = !inQuote
+		case (r == ' ' || r == '\t') && !inQuote:
+			if token.Len() > 0 {
+				tokens = append(tokens, token.String())
+				token.Reset()
+			}
+		default:
+			token.WriteRune(r)
+		}
+	}
+
+	if token.Len() > 0 {
+		tokens = append(tokens, token.String())
+	}
+
+	return tokens
+}
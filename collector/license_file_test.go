@@ -0,0 +1,132 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/mjtrangoni/flexlm_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/promslog"
+)
+
+const (
+	testLicenseFile1 = "fixtures/license_app1.dat"
+)
+
+func TestReadLicenseFile(t *testing.T) {
+	t.Parallel()
+
+	logger := promslog.New(&promslog.Config{})
+
+	lf, err := readLicenseFile(testLicenseFile1, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(lf.servers) != 2 || lf.servers[1].host != "host2.domain.net" ||
+		lf.servers[1].hostid != "0123456789AC" || lf.servers[1].port != "27000" {
+		t.Fatalf("Unexpected servers: %+v", lf.servers)
+	}
+
+	if len(lf.vendors) != 1 || lf.vendors[0].name != "VENDOR1" || lf.vendors[0].port != "27001" ||
+		lf.vendors[0].options != "/opt/flexlm/VENDOR1.opt" {
+		t.Fatalf("Unexpected vendors: %+v", lf.vendors)
+	}
+
+	if len(lf.features) != 7 {
+		t.Fatalf("Unexpected number of features: %d != 7", len(lf.features))
+	}
+
+	f := lf.features[0]
+	if f.lineType != licenseLineFeature || f.name != "feature1" || f.version != "61.9" || f.count != 10 ||
+		f.expires != 1546214400 || f.start != 1514764800 || f.vendorInfo != "site=HQ" ||
+		f.issuer != "ACME Inc." || f.sn != "12345" || f.uncounted || f.nodeLocked {
+		t.Fatalf("Unexpected continued FEATURE line: %+v", f)
+	}
+
+	if f = lf.features[2]; !math.IsInf(f.expires, posInfinity) || f.count != 2 {
+		t.Fatalf("Unexpected permanent INCREMENT line: %+v", f)
+	}
+
+	if f = lf.features[3]; !f.uncounted || !f.nodeLocked || f.hostid != "001122334455" {
+		t.Fatalf("Unexpected uncounted node-locked INCREMENT line: %+v", f)
+	}
+
+	if f = lf.features[4]; !f.uncounted || f.nodeLocked {
+		t.Fatalf("Unexpected uncounted FEATURE line: %+v", f)
+	}
+
+	if f = lf.features[5]; f.lineType != licenseLineUpgrade || f.version != "62.0" || f.count != 4 {
+		t.Fatalf("Unexpected UPGRADE line: %+v", f)
+	}
+
+	if len(lf.packages) != 1 || lf.packages[0].name != "suite1" ||
+		len(lf.packages[0].components) != 2 || lf.packages[0].components[1] != "feature3" {
+		t.Fatalf("Unexpected packages: %+v", lf.packages)
+	}
+}
+
+func TestLicenseFileFeatureInfo(t *testing.T) {
+	t.Parallel()
+
+	path := filepath.Join(t.TempDir(), "license.dat")
+	line := "INCREMENT feature1 VENDOR1 61.9 31-dec-2018 5 SN=12346 SIGN=\"AAAA BBBB\"\n"
+
+	if err := os.WriteFile(path, []byte(line+line), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	c, err := NewLicenseFileCollector(promslog.New(&promslog.Config{}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	collector, ok := c.(*licenseFileCollector)
+	if !ok {
+		t.Fatalf("unexpected collector %T", c)
+	}
+
+	ch := make(chan prometheus.Metric, 16)
+	if err := collector.collect(&config.License{Name: "app1", LicenseFile: path}, ch); err != nil {
+		t.Fatal(err)
+	}
+
+	close(ch)
+
+	values := make(map[string][]float64)
+
+	for m := range ch {
+		var metric dto.Metric
+		if err := m.Write(&metric); err != nil {
+			t.Fatal(err)
+		}
+
+		values[m.Desc().String()] = append(values[m.Desc().String()], metric.GetGauge().GetValue())
+	}
+
+	// The identical lines are merged: the info metric stays 1, the counts add up.
+	for desc, want := range map[*prometheus.Desc]float64{
+		collector.licenseFeatureInfo:  1,
+		collector.licenseFeatureCount: 10,
+	} {
+		if got := values[desc.String()]; len(got) != 1 || got[0] != want {
+			t.Errorf("%s: want [%v], got %v", desc, want, got)
+		}
+	}
+}
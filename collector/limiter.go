@@ -0,0 +1,138 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"sync"
+	"time"
+
+	kingpin "github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	lmutilWaitBucketStart  = 0.01
+	lmutilWaitBucketFactor = 4
+	lmutilWaitBucketCount  = 8
+)
+
+var (
+	lmutilMaxConcurrency = kingpin.Flag("lmutil.max-concurrency",
+		"Maximum number of lmutil processes running at once, shared by all the collectors. Use 0 to disable.").
+		Default("10").Int()
+
+	lmutilInFlightDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "lmutil", "in_flight"),
+		"flexlm_exporter: Number of lmutil processes running.",
+		nil, nil,
+	)
+	lmutilWaitingDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "lmutil", "waiting"),
+		"flexlm_exporter: Number of lmutil calls waiting for --lmutil.max-concurrency.",
+		nil, nil,
+	)
+
+	// lmutilSlots limits the lmutil processes of all the collectors.
+	lmutilSlots = newLmutilLimiter()
+)
+
+// lmutilLimiter is a semaphore for the lmutil processes. The waiting calls
+// are served in turn by app, so that an app with many calls, or slow ones,
+// doesn't starve the others.
+type lmutilLimiter struct {
+	mtx         sync.Mutex
+	inFlight    int
+	waitingN    int
+	waiting     map[string][]chan struct{}
+	apps        []string
+	waitSeconds prometheus.Histogram
+}
+
+func newLmutilLimiter() *lmutilLimiter {
+	return &lmutilLimiter{
+		waiting: make(map[string][]chan struct{}),
+		waitSeconds: prometheus.NewHistogram(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "lmutil",
+			Name:      "wait_seconds",
+			Help:      "flexlm_exporter: Time lmutil calls waited for --lmutil.max-concurrency.",
+			Buckets:   prometheus.ExponentialBuckets(lmutilWaitBucketStart, lmutilWaitBucketFactor, lmutilWaitBucketCount),
+		}),
+	}
+}
+
+// acquire waits for a slot to run lmutil for an app.
+func (l *lmutilLimiter) acquire(app string, limit int) {
+	begin := time.Now()
+
+	l.mtx.Lock()
+
+	if limit <= 0 || (l.inFlight < limit && len(l.apps) == 0) {
+		l.inFlight++
+		l.mtx.Unlock()
+		l.waitSeconds.Observe(0)
+
+		return
+	}
+
+	ready := make(chan struct{})
+	if len(l.waiting[app]) == 0 {
+		l.apps = append(l.apps, app)
+	}
+
+	l.waiting[app] = append(l.waiting[app], ready)
+	l.waitingN++
+	l.mtx.Unlock()
+
+	<-ready
+	l.waitSeconds.Observe(time.Since(begin).Seconds())
+}
+
+// release hands the slot over to the next app waiting, if any.
+func (l *lmutilLimiter) release() {
+	l.mtx.Lock()
+	defer l.mtx.Unlock()
+
+	if len(l.apps) == 0 {
+		l.inFlight--
+		return
+	}
+
+	app := l.apps[0]
+	l.apps = l.apps[1:]
+
+	queue := l.waiting[app]
+	if len(queue) > 1 {
+		l.waiting[app] = queue[1:]
+		l.apps = append(l.apps, app)
+	} else {
+		delete(l.waiting, app)
+	}
+
+	l.waitingN--
+	close(queue[0])
+}
+
+// collect sends the lmutil processes running and waiting, and their wait time.
+func (l *lmutilLimiter) collect(ch chan<- prometheus.Metric) {
+	l.mtx.Lock()
+	inFlight, waiting := l.inFlight, l.waitingN
+	l.mtx.Unlock()
+
+	ch <- prometheus.MustNewConstMetric(lmutilInFlightDesc, prometheus.GaugeValue, float64(inFlight))
+
+	ch <- prometheus.MustNewConstMetric(lmutilWaitingDesc, prometheus.GaugeValue, float64(waiting))
+
+	l.waitSeconds.Collect(ch)
+}
@@ -0,0 +1,80 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestLmutilLimiter(t *testing.T) {
+	t.Parallel()
+
+	l := newLmutilLimiter()
+	waiting := func() int {
+		l.mtx.Lock()
+		defer l.mtx.Unlock()
+
+		return l.waitingN
+	}
+
+	l.acquire("app1", 1)
+
+	// app1 queues three calls before app2 queues one.
+	order := make(chan string)
+
+	for i, app := range []string{"app1", "app1", "app1", "app2"} {
+		go func() {
+			l.acquire(app, 1)
+			order <- app
+		}()
+
+		for waiting() != i+1 {
+			time.Sleep(time.Millisecond)
+		}
+	}
+
+	got := make([]string, 0, 4)
+
+	for range 4 {
+		l.release()
+		got = append(got, <-order)
+	}
+
+	if want := []string{"app1", "app2", "app1", "app1"}; !reflect.DeepEqual(got, want) {
+		t.Fatalf("want %v served in turn, got %v", want, got)
+	}
+
+	if l.inFlight != 1 || waiting() != 0 {
+		t.Fatalf("want 1 in flight and none waiting, got %d and %d", l.inFlight, waiting())
+	}
+
+	l.release()
+
+	if count := testutil.CollectAndCount(l.waitSeconds); count != 1 {
+		t.Fatalf("Unexpected wait time series: %d != 1", count)
+	}
+
+	// Without limit, the calls never wait.
+	for range 3 {
+		l.acquire("app1", 0)
+	}
+
+	if l.inFlight != 3 || waiting() != 0 {
+		t.Fatalf("want 3 in flight and none waiting, got %d and %d", l.inFlight, waiting())
+	}
+}
@@ -14,7 +14,6 @@
 package collector
 
 import (
-	"bufio"
 	"bytes"
 	"context"
 	"fmt"
@@ -27,28 +26,55 @@ import (
 	"sync"
 	"time"
 
+	kingpin "github.com/alecthomas/kingpin/v2"
 	"github.com/mjtrangoni/flexlm_exporter/config"
+	"github.com/mjtrangoni/flexlm_exporter/lmstat"
 	"github.com/prometheus/client_golang/prometheus"
 )
 
 type lmstatCollector struct {
-	lmstatInfo                     *prometheus.Desc
-	lmstatServerStatus             *prometheus.Desc
-	lmstatVendorStatus             *prometheus.Desc
-	lmstatFeatureUsed              *prometheus.Desc
-	lmstatFeatureUsedUsers         *prometheus.Desc
-	lmstatFeatureUsedUsersVersions *prometheus.Desc
-	lmstatFeatureReservGroups      *prometheus.Desc
-	lmstatFeatureReservHost        *prometheus.Desc
-	lmstatFeatureIssued            *prometheus.Desc
-	logger                         *slog.Logger
+	lmstatInfo                       *prometheus.Desc
+	lmstatServerStatus               *prometheus.Desc
+	lmstatVendorStatus               *prometheus.Desc
+	lmstatFeatureUsed                *prometheus.Desc
+	lmstatFeatureUsedUsers           *prometheus.Desc
+	lmstatFeatureUsedUsersVersions   *prometheus.Desc
+	lmstatFeatureReservGroups        *prometheus.Desc
+	lmstatFeatureReservHost          *prometheus.Desc
+	lmstatFeatureReserved            *prometheus.Desc
+	lmstatFeatureIssued              *prometheus.Desc
+	lmstatFeatureUsedMax             *prometheus.Desc
+	lmstatFeatureUsedMin             *prometheus.Desc
+	lmstatPackageInfo                *prometheus.Desc
+	lmstatFeaturePoolUsed            *prometheus.Desc
+	lmstatOptionsReserved            *prometheus.Desc
+	lmstatOptionsMax                 *prometheus.Desc
+	lmstatOptionsRuleInfo            *prometheus.Desc
+	lmstatOptionsGroupInfo           *prometheus.Desc
+	lmstatOptionsReservationExceeded *prometheus.Desc
+	lmstatFeatureReservationUsed     *prometheus.Desc
+	sessions                         *sessionTracker
+	peaks                            *peakTracker
+	logger                           *slog.Logger
 }
 
 // LicenseConfig is going to be read once in main, and then used here.
 var LicenseConfig config.Configuration
 
+var (
+	lmstatPollInterval = kingpin.Flag("collector.lmstat.poll-interval",
+		"Interval to poll lmstat in the background between scrapes. Use 0 to disable.").Default("0s").Duration()
+	lmstatPeakWindow = kingpin.Flag("collector.lmstat.peak-window",
+		"Window over which the feature usage peaks are tracked. Use 0 to reset them on each scrape.").Default("0s").Duration()
+	lmstatMaxFeatureQueries = kingpin.Flag("collector.lmstat.max-feature-queries",
+		"Maximum number of `features_to_include` queried one by one with lmstat -f instead of lmstat -a. Use 0 to disable.").
+		Default("5").Int()
+)
+
 const (
 	notFound = "not found"
+	// Number of unparsed lmstat output lines logged by scrape.
+	maxUnparsedSamples = 5
 )
 
 func init() {
@@ -57,7 +83,7 @@ func init() {
 
 // NewLmstatCollector returns a new Collector exposing lmstat license stats.
 func NewLmstatCollector(logger *slog.Logger) (Collector, error) {
-	return &lmstatCollector{
+	c := &lmstatCollector{
 		lmstatInfo: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "lmstat", "info"),
 			"A metric with a constant '1' value labeled by arch, build and version of the lmstat tool.",
@@ -100,13 +126,79 @@ func NewLmstatCollector(logger *slog.Logger) (Collector, error) {
 				"and host name of the license.", []string{appString, nameString, "host"},
 			nil,
 		),
+		lmstatFeatureReserved: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature", "reserved"),
+			"License feature reserved labeled by app, feature name, reservation type and target, "+
+				"i.e. the user, host, display, internet address, project, group or host group.",
+			[]string{appString, nameString, "type", "target"}, nil,
+		),
 		lmstatFeatureIssued: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "feature", "issued"),
 			"License feature issued labeled by app, feature name and license type of the license.",
 			[]string{appString, nameString, "type"}, nil,
 		),
-		logger: logger,
-	}, nil
+		lmstatFeatureUsedMax: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature", "used_max"),
+			"Maximum license feature used over the scrape window labeled by app and feature name.",
+			[]string{appString, nameString}, nil,
+		),
+		lmstatFeatureUsedMin: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature", "used_min"),
+			"Minimum license feature used over the scrape window labeled by app and feature name.",
+			[]string{appString, nameString}, nil,
+		),
+		lmstatPackageInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "package", "info"),
+			"A metric with a constant '1' value labeled by app, package and component name, "+
+				"to aggregate the usage of suite components.",
+			[]string{appString, "package", "component"}, nil,
+		),
+		lmstatFeaturePoolUsed: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature", "pool_used"),
+			"License feature used by pool, i.e. by INCREMENT line, labeled by app, feature name, and pool, version and expiry "+
+				"of the pool.",
+			[]string{appString, nameString, "pool", versionString, "expiry"}, nil,
+		),
+		lmstatOptionsReserved: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "options", "reserved"),
+			"License feature reserved in the options file labeled by app, feature name, type and target "+
+				"of the RESERVE lines.",
+			[]string{appString, nameString, "type", "target"}, nil,
+		),
+		lmstatOptionsMax: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "options", "max"),
+			"License feature limit in the options file labeled by app, feature name, type and target of the MAX lines.",
+			[]string{appString, nameString, "type", "target"}, nil,
+		),
+		lmstatOptionsRuleInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "options", "rule_info"),
+			"A metric with a constant '1' value labeled by app, feature name, rule, type and target "+
+				"of the INCLUDE, EXCLUDE, INCLUDEALL and EXCLUDEALL lines of the options file.",
+			[]string{appString, nameString, "rule", "type", "target"}, nil,
+		),
+		lmstatOptionsGroupInfo: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "options", "group_info"),
+			"A metric with a constant '1' value labeled by app, group, type and member "+
+				"of the GROUP and HOST_GROUP lines of the options file.",
+			[]string{appString, "group", "type", "member"}, nil,
+		),
+		lmstatOptionsReservationExceeded: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "options", "reservation_exceeds_issued"),
+			"Whether the options file reserves more licenses than issued labeled by app and feature name.",
+			[]string{appString, nameString}, nil,
+		),
+		lmstatFeatureReservationUsed: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature", "reservation_used"),
+			"License feature reserved by group and used by its members labeled by app, feature name, group type "+
+				"(group or host_group) and group name.",
+			[]string{appString, nameString, "type", "group"}, nil,
+		),
+		sessions: newSessionTracker(),
+		peaks:    newPeakTracker(*lmstatPeakWindow),
+		logger:   logger,
+	}
+
+	return c, nil
 }
 
 // Update calls (*lmstatCollector).getLmStat to get the platform specific
@@ -122,6 +214,8 @@ func (c *lmstatCollector) Update(ch chan<- prometheus.Metric) error {
 		return fmt.Errorf("couldn't get licenses information: %w", err)
 	}
 
+	c.sessions.collect(ch)
+
 	return nil
 }
 
@@ -137,139 +231,115 @@ func contains(slice []string, item string) bool {
 	return ok
 }
 
-// execute lmutil utility.
-func lmutilOutput(logger *slog.Logger, args ...string) ([]byte, error) {
+// execute lmutil utility, or replay the saved output of the app with
+// --lmutil.replay-dir. The output is saved in --lmutil.record-dir if set.
+func lmutilOutput(logger *slog.Logger, app string, args ...string) ([]byte, error) {
+	if *lmutilReplayDir != "" {
+		return replayOutput(logger, app, args)
+	}
+
 	_, err := os.Stat(*lmutilPath)
 	if os.IsNotExist(err) {
 		logger.Error("err", *lmutilPath, "missing")
 		os.Exit(1)
 	}
 
+	lmutilSlots.acquire(app, *lmutilMaxConcurrency)
+	defer lmutilSlots.release()
+
 	ctx := context.Background()
 	cmd := exec.CommandContext(ctx, *lmutilPath, args...)
 	// Disable localization for parsing.
 	cmd.Env = append(os.Environ(), "LANG=C")
 
+	begin := time.Now()
 	out, err := cmd.Output()
-	if err != nil {
-		// convert error to strings
-		errorToString := errorDescriptionString[err.Error()]
-		if errorToString != "" {
-			return nil, fmt.Errorf("error while calling '%s %s': %w:'%s'", *lmutilPath,
-				strings.Join(args, " "), err, errorToString)
-		}
+	observeLmutil(app, args, time.Since(begin), out, err)
 
-		return nil, fmt.Errorf("error while calling '%s %s': %w:'unknown error'",
-			*lmutilPath, strings.Join(args, " "), err)
+	if *lmutilRecordDir != "" && len(out) > 0 {
+		recordOutput(logger, app, args, out, time.Now())
+	}
+
+	if err != nil {
+		return nil, newLmutilError(args, err)
 	}
 
 	return out, nil
 }
 
-// splitOutput splits the lmutil output into lines and removes comments.
-func splitOutput(lmutilOutput []byte) ([][]string, error) {
-	scanner := bufio.NewScanner(bytes.NewReader(lmutilOutput))
-	scanner.Split(bufio.ScanLines)
-
-	var result [][]string
+// parseLmstatOutput parses the lmutil lmstat output, and logs a sample of the
+// lines that are not recognized. The logger of the collector already has the
+// collector attribute.
+func parseLmstatOutput(out []byte, app string, logger *slog.Logger) (*lmstat.Document, error) {
+	doc, err := lmstat.Parse(bytes.NewReader(out))
+	if err != nil {
+		return nil, err
+	}
 
-	for scanner.Scan() {
-		line := scanner.Text()
-		if strings.HasPrefix(line, "#") {
-			continue
-		}
+	for i, d := range doc.Diagnostics {
+		if i == maxUnparsedSamples {
+			logger.Debug("more lmstat output lines couldn't be parsed", "app", app, "lines", len(doc.Diagnostics)-maxUnparsedSamples)
 
-		if strings.Contains(line, "|") {
-			line = strings.ReplaceAll(line, "|", "Ž")
+			break
 		}
 
-		result = append(result, strings.Split(line, "Ž"))
+		logger.Debug("couldn't parse lmstat output", "app", app, "line", d.Line, "section", d.Section, "text", d.Text)
 	}
 
-	if err := scanner.Err(); err != nil {
-		return result, fmt.Errorf("could not parse lmutil output: %w", err)
+	return doc, nil
+}
+
+// licenseTypeLabel returns the license type label of an lmstat license type.
+func licenseTypeLabel(licenseType string) string {
+	if licenseType == lmstat.LicenseTypeNodeLocked || licenseType == lmstat.LicenseTypeUncountedNodeLocked {
+		return licenseTypeNodeLocked
 	}
 
-	return result, nil
+	return licenseType
 }
 
 // parseLmstatVersion parses the lmstat version information.
-func parseLmstatVersion(outStr [][]string) lmstatInformation {
-	for _, line := range outStr {
-		lineJoined := strings.Join(line, "")
-		if lmutilVersionRegex.MatchString(lineJoined) {
-			names := lmutilVersionRegex.SubexpNames()
-			matches := lmutilVersionRegex.FindAllStringSubmatch(lineJoined, -1)[0]
-
-			md := map[string]string{}
-			for i, n := range matches {
-				md[names[i]] = n
-			}
-
-			return lmstatInformation{
-				arch:    md["arch"],
-				build:   md["build"],
-				version: md[versionString],
-			}
-		}
+func parseLmstatVersion(doc *lmstat.Document) lmstatInformation {
+	if doc.Header.Version == "" {
+		return lmstatInformation{arch: notFound, build: notFound, version: notFound}
 	}
 
-	return lmstatInformation{arch: notFound, build: notFound, version: notFound}
+	return lmstatInformation{
+		arch:    doc.Header.Arch,
+		build:   doc.Header.Build,
+		version: doc.Header.Version,
+	}
 }
 
-func parseLmstatLicenseInfoServer(outStr [][]string) map[string]*server {
+func parseLmstatLicenseInfoServer(doc *lmstat.Document) map[string]*server {
 	servers := make(map[string]*server)
 
-	for _, line := range outStr {
-		lineJoined := strings.Join(line, "")
-		if lmutilLicenseServersRegex.MatchString(lineJoined) {
-			matches := lmutilLicenseServersRegex.FindStringSubmatch(lineJoined)[1]
-			for portServer := range strings.SplitSeq(matches, ",") {
-				fqdn := strings.ToLower(strings.Split(portServer, "@")[1])
-				servers[strings.Split(fqdn, ".")[0]] = &server{
-					fqdn: fqdn, port: strings.Split(portServer, "@")[0],
-				}
-			}
-		} else if lmutilLicenseServerStatusRegex.MatchString(lineJoined) {
-			matches := lmutilLicenseServerStatusRegex.FindStringSubmatch(lineJoined)
-			servers[strings.ToLower(strings.Split(matches[1], ".")[0])].version = matches[4]
-
-			if matches[2] == upString {
-				servers[strings.ToLower(strings.Split(matches[1], ".")[0])].status = true
-			}
-
-			if matches[3] == " (MASTER)" {
-				servers[strings.ToLower(strings.Split(matches[1], ".")[0])].master = true
-			}
+	for _, s := range doc.Servers {
+		fqdn := strings.ToLower(s.Name)
+		servers[strings.Split(fqdn, ".")[0]] = &server{
+			fqdn: fqdn, port: s.Port, version: s.Version, status: s.Up, master: s.Master,
 		}
 	}
 
 	return servers
 }
 
-func parseLmstatLicenseInfoVendor(outStr [][]string) map[string]*vendor {
+func parseLmstatLicenseInfoVendor(doc *lmstat.Document) map[string]*vendor {
 	vendors := make(map[string]*vendor)
 
-	for _, line := range outStr {
-		lineJoined := strings.Join(line, "")
-		if lmutilLicenseVendorStatusRegex.MatchString(lineJoined) {
-			matches := lmutilLicenseVendorStatusRegex.FindStringSubmatch(lineJoined)
-			if matches[2] == upString {
-				vendors[matches[1]] = &vendor{
-					status: true, version: matches[3],
-				}
-			} else {
-				vendors[matches[1]] = &vendor{
-					status: false, version: matches[3],
-				}
-			}
+	for _, v := range doc.Vendors {
+		// The vendor daemons without status have no version either.
+		if v.Error != "" {
+			continue
 		}
+
+		vendors[v.Name] = &vendor{status: v.Up, version: v.Version}
 	}
 
 	return vendors
 }
 
-func parseLmstatLicenseInfoFeature(outStr [][]string, logger *slog.Logger) (features map[string]*feature,
+func parseLmstatLicenseInfoFeature(doc *lmstat.Document, logger *slog.Logger) (features map[string]*feature,
 	licUsersByFeature map[string]map[string][]*featureUserUsed, reservGroupByFeature map[string]map[string]float64,
 	reservHostByFeature map[string]map[string]float64) {
 	features = make(map[string]*feature)
@@ -277,229 +347,193 @@ func parseLmstatLicenseInfoFeature(outStr [][]string, logger *slog.Logger) (feat
 	reservGroupByFeature = make(map[string]map[string]float64)
 	reservHostByFeature = make(map[string]map[string]float64)
 
-	var (
-		// featureName saved here as index for the user and reservation information.
-		featureName        string
-		currentLicenseType string
-	)
-
-	for _, line := range outStr {
-		lineJoined := strings.Join(line, "")
-
-		switch {
-		case lmutilLicenseFeatureUsageRegex.MatchString(lineJoined):
-			matches := lmutilLicenseFeatureUsageRegex.FindStringSubmatch(lineJoined)
+	// userUsed returns the usage of a user for a feature version, the first
+	// session of the version sets the start time.
+	userUsed := func(name, username, version, since string) *featureUserUsed {
+		if licUsersByFeature[name] == nil {
+			licUsersByFeature[name] = map[string][]*featureUserUsed{}
+		}
 
-			issued, err := strconv.Atoi(matches[2])
-			if err != nil {
-				logger.Error("err", "could not convert", matches[2], "to integer:", err)
+		for _, used := range licUsersByFeature[name][username] {
+			if used.version == version {
+				return used
 			}
+		}
 
-			featureName = matches[1]
-			currentLicenseType = licenseTypeFloating
-
-			used, err := strconv.Atoi(matches[3])
-			if err != nil {
-				logger.Error("err", "could not convert", matches[3], "to integer:", err)
-			}
+		used := &featureUserUsed{version: version, since: since}
+		licUsersByFeature[name][username] = append(licUsersByFeature[name][username], used)
 
-			features[featureName] = &feature{
-				issued:      float64(issued),
-				used:        float64(used),
-				licenseType: licenseTypeFloating,
-				usedByType:  map[string]float64{},
-			}
-		case lmutilLicenseFeatureUsageNodeLockedRegex.MatchString(lineJoined):
-			matches := lmutilLicenseFeatureUsageNodeLockedRegex.FindStringSubmatch(lineJoined)
-			featureName = matches[1]
-			currentLicenseType = licenseTypeNodeLocked
-			features[featureName] = &feature{
-				licenseType: licenseTypeNodeLocked,
-				usedByType:  map[string]float64{},
-			}
-		case lmutilLicenseFeatureTypeRegex.MatchString(lineJoined):
-			if featureName != "" && features[featureName] != nil {
-				matches := reSubMatchMap(lmutilLicenseFeatureTypeRegex, lineJoined)
-				if matches["type"] == "uncounted nodelocked" || matches["type"] == "nodelocked" {
-					currentLicenseType = licenseTypeNodeLocked
-				} else {
-					currentLicenseType = matches["type"]
-				}
-				// We keep info.licenseType as the last seen type for defaults, but usedByType handles metrics
-				features[featureName].licenseType = currentLicenseType
-			}
-		case lmutilLicenseFeatureUsageUserRegex.MatchString(lineJoined):
-			if licUsersByFeature[featureName] == nil {
-				licUsersByFeature[featureName] = map[string][]*featureUserUsed{}
-			}
+		return used
+	}
 
-			matches := reSubMatchMap(lmutilLicenseFeatureUsageUserRegex, lineJoined)
-			username := matches["user"]
+	for _, f := range doc.Features {
+		if f.Error != "" {
+			continue
+		}
 
-			if strings.TrimSpace(username) == "" {
-				logger.Debug("username couldn't be found for '", lineJoined,
-					"', using lmutilLicenseFeatureUsageUser2Regex.")
+		info := &feature{
+			issued:      float64(f.Issued),
+			used:        float64(f.Used),
+			licenseType: licenseTypeLabel(f.LicenseType),
+			usedByType:  map[string]float64{},
+		}
+		features[f.Name] = info
 
-				matches = reSubMatchMap(lmutilLicenseFeatureUsageUser2Regex, lineJoined)
-				username = matches["user"]
-			}
+		for _, s := range f.Sessions {
+			since := strconv.FormatInt(convertLmstatTimeToUnixTime(s.Start, logger).Unix(), 10)
+			userUsed(f.Name, s.User, "(v"+s.Version+")", since).num += float64(s.Licenses)
+			info.usedByType[licenseTypeLabel(s.LicenseType)] += float64(s.Licenses)
+		}
 
-			if matches["ver"] != "" {
-				var found = -1
+		// Queued licenses are added on top of the "in use" count, so that
+		// flexlm_feature_used can exceed flexlm_feature_issued when the
+		// server is overloaded. Queued entries do not provide a start time;
+		// use a synthetic "since" value so queued licenses are still visible
+		// in flexlm_feature_used_users while keeping the label set stable.
+		for _, q := range f.Queues {
+			since := strconv.FormatInt(time.Now().Unix(), 10)
+			userUsed(f.Name, q.User, "(v"+q.Version+")", since).num += float64(q.Licenses)
+			info.used += float64(q.Licenses)
+			info.usedByType[licenseTypeLabel(q.LicenseType)] += float64(q.Licenses)
+		}
 
-				for i := range licUsersByFeature[featureName][username] {
-					if licUsersByFeature[featureName][username][i].version == matches["ver"] {
-						found = i
-					}
+		for _, r := range f.Reservations {
+			switch r.Type {
+			case optionsGroup, optionsHostGroup:
+				if reservGroupByFeature[f.Name] == nil {
+					reservGroupByFeature[f.Name] = map[string]float64{}
 				}
 
-				if found < 0 {
-					unixSince := convertLmstatTimeToUnixTime(matches["since"], logger).Unix()
-					sinceString := strconv.FormatInt(unixSince, 10)
-					licUsersByFeature[featureName][username] = append(licUsersByFeature[featureName][username],
-						&featureUserUsed{num: 0, version: matches["ver"], since: sinceString})
+				reservGroupByFeature[f.Name][r.Target] = float64(r.Count)
+			case "HOST":
+				if reservHostByFeature[f.Name] == nil {
+					reservHostByFeature[f.Name] = map[string]float64{}
 				}
+
+				reservHostByFeature[f.Name][r.Target] = float64(r.Count)
 			}
 
-			if matches["licenses"] != "" {
-				licUsed, err := strconv.Atoi(matches["licenses"])
-				if err != nil {
-					logger.Error("err", "could not convert", matches["licenses"], "to integer:", err)
-				}
+			info.usedByType[licenseTypeLabel(r.LicenseType)] += float64(r.Count)
+		}
+	}
 
-				for i := range licUsersByFeature[featureName][username] {
-					if licUsersByFeature[featureName][username][i].version == matches["ver"] {
-						licUsersByFeature[featureName][username][i].num += float64(licUsed)
-					}
-				}
+	return features, licUsersByFeature, reservGroupByFeature, reservHostByFeature
+}
 
-				if features[featureName] != nil {
-					features[featureName].usedByType[currentLicenseType] += float64(licUsed)
-				}
-			} else {
-				for i := range licUsersByFeature[featureName][username] {
-					if licUsersByFeature[featureName][username][i].version == matches["ver"] {
-						licUsersByFeature[featureName][username][i].num += 1.0
-					}
-				}
+// parseLmstatLicenseInfoReservations returns the reserved licenses by feature,
+// reservation type and target, e.g. "2 RESERVATIONs for HOST node-01.cluster".
+func parseLmstatLicenseInfoReservations(doc *lmstat.Document) map[string]map[featureReservation]float64 {
+	reservations := make(map[string]map[featureReservation]float64)
 
-				if features[featureName] != nil {
-					features[featureName].usedByType[currentLicenseType] += 1.0
-				}
-			}
-		case lmutilLicenseFeatureUsageUserQueuedRegex.MatchString(lineJoined):
-			// Queued license lines look like regular user lines but end with
-			// "queued for N license" instead of a "start" timestamp. We only
-			// care about the username, feature version and queued count here.
-			//
-			// Assumptions for simplicity:
-			//   * Every feature with queued licenses also has at least one
-			//     non-queued "used" license line.
-			//   * At least one such "used" line appears before any queued line
-			//     for that feature.
-			//
-			// This means the summary case above has already initialized
-			// features[featureName] with the "in use" count, so we only need to
-			// add the queued licenses on top of that here.
-			if licUsersByFeature[featureName] == nil {
-				licUsersByFeature[featureName] = map[string][]*featureUserUsed{}
+	for _, f := range doc.Features {
+		for _, r := range f.Reservations {
+			if reservations[f.Name] == nil {
+				reservations[f.Name] = map[featureReservation]float64{}
 			}
 
-			matches := reSubMatchMap(lmutilLicenseFeatureUsageUserQueuedRegex, lineJoined)
-			username := matches["user"]
+			reservations[f.Name][featureReservation{
+				targetType: strings.ToLower(r.Type),
+				target:     r.Target,
+			}] += float64(r.Count)
+		}
+	}
 
-			if matches["ver"] != "" {
-				var found = -1
+	return reservations
+}
 
-				for i := range licUsersByFeature[featureName][username] {
-					if licUsersByFeature[featureName][username][i].version == matches["ver"] {
-						found = i
-					}
-				}
+// parseLmstatLicenseInfoPackages returns the components by package. A feature
+// is a component of a package, when it is checked out from a pool with a
+// different name, e.g. "Users of feature1" followed by "feature0" v61.9.
+func parseLmstatLicenseInfoPackages(doc *lmstat.Document) map[string]map[string]bool {
+	packages := make(map[string]map[string]bool)
 
-				if found < 0 {
-					// Queued entries do not provide a start time; use a synthetic
-					// "since" value so queued licenses are still visible in
-					// flexlm_feature_used_users while keeping the label set stable.
-					sinceString := strconv.FormatInt(time.Now().Unix(), 10)
-					licUsersByFeature[featureName][username] = append(
-						licUsersByFeature[featureName][username],
-						&featureUserUsed{num: 0, version: matches["ver"], since: sinceString})
-				}
+	for _, f := range doc.Features {
+		for _, pool := range f.Pools {
+			if pool.Name == f.Name {
+				continue
 			}
 
-			if matches["licenses"] != "" {
-				licQueued, err := strconv.Atoi(matches["licenses"])
-				if err != nil {
-					logger.Error("err", "could not convert", matches["licenses"], "to integer:", err)
-				}
-
-				for i := range licUsersByFeature[featureName][username] {
-					if licUsersByFeature[featureName][username][i].version == matches["ver"] {
-						licUsersByFeature[featureName][username][i].num += float64(licQueued)
-					}
-				}
-
-				// Add queued licenses on top of the "in use" count so that
-				// flexlm_feature_used can exceed flexlm_feature_issued when the
-				// server is overloaded.
-				features[featureName].used += float64(licQueued)
-				features[featureName].usedByType[currentLicenseType] += float64(licQueued)
-			}
-		case lmutilLicenseFeatureGroupReservRegex.MatchString(lineJoined):
-			if reservGroupByFeature[featureName] == nil {
-				reservGroupByFeature[featureName] = map[string]float64{}
+			if packages[pool.Name] == nil {
+				packages[pool.Name] = map[string]bool{}
 			}
 
-			matches := lmutilLicenseFeatureGroupReservRegex.FindStringSubmatch(lineJoined)
+			packages[pool.Name][f.Name] = true
+		}
+	}
 
-			groupReserv, err := strconv.Atoi(matches[2])
-			if err != nil {
-				logger.Error("err", "could not convert", matches[1], "to integer:", err)
-			}
+	return packages
+}
 
-			reservGroupByFeature[featureName][matches[4]] = float64(groupReserv)
-			if features[featureName] != nil {
-				features[featureName].usedByType[currentLicenseType] += float64(groupReserv)
-			}
-		case lmutilLicenseFeatureHostReservRegex.MatchString(lineJoined):
-			if reservHostByFeature[featureName] == nil {
-				reservHostByFeature[featureName] = map[string]float64{}
-			}
+// parseLmstatLicenseInfoPools returns the licenses checked out by feature and
+// pool. A feature with several INCREMENT lines has a pool for each version and
+// expiry date, e.g. "MATLAB" v53, vendor: MLM, expiry: 01-jan-2099, and a
+// suite component one for each package it is checked out from. lmstat prints
+// nothing else that tells pools apart, so the usage of the pools with the same
+// name, version and expiry date is summed.
+func parseLmstatLicenseInfoPools(doc *lmstat.Document, logger *slog.Logger) map[string]map[featurePool]float64 {
+	pools := make(map[string]map[featurePool]float64)
+
+	poolKey := func(pool *lmstat.Pool) featurePool {
+		key := featurePool{name: pool.Name, version: pool.Version}
+		if pool.Expiry != "" {
+			key.expiry = dateLabel(parseExpirationDate(pool.Expiry, logger))
+		}
 
-			matches := lmutilLicenseFeatureHostReservRegex.FindStringSubmatch(lineJoined)
+		return key
+	}
 
-			hostReserv, err := strconv.Atoi(matches[2])
-			if err != nil {
-				logger.Error("err", "could not convert", matches[1], "to integer:", err)
+	for _, f := range doc.Features {
+		for _, pool := range f.Pools {
+			if pools[f.Name] == nil {
+				pools[f.Name] = map[featurePool]float64{}
 			}
 
-			reservHostByFeature[featureName][matches[4]] = float64(hostReserv)
-			if features[featureName] != nil {
-				features[featureName].usedByType[currentLicenseType] += float64(hostReserv)
+			pools[f.Name][poolKey(pool)] += 0
+		}
+
+		for _, s := range f.Sessions {
+			if s.Pool != nil {
+				pools[f.Name][poolKey(s.Pool)] += float64(s.Licenses)
 			}
 		}
 	}
 
-	// Removed the recalculation logic, as we now dynamically populate usedByType
-	// for each license block while iterating!
+	return pools
+}
 
-	return features, licUsersByFeature, reservGroupByFeature, reservHostByFeature
+// parseLmstatLicenseInfoSessions returns the checked out sessions by feature.
+// Queued requests are not sessions and are therefore skipped.
+func parseLmstatLicenseInfoSessions(doc *lmstat.Document, logger *slog.Logger) map[string][]*featureSession {
+	sessionsByFeature := make(map[string][]*featureSession)
+
+	for _, f := range doc.Features {
+		for _, s := range f.Sessions {
+			sessionsByFeature[f.Name] = append(sessionsByFeature[f.Name], &featureSession{
+				user:     s.User,
+				host:     s.Host,
+				handle:   s.Handle,
+				since:    convertLmstatTimeToUnixTime(s.Start, logger).Unix(),
+				licenses: float64(s.Licenses),
+			})
+		}
+	}
+
+	return sessionsByFeature
 }
 
 // getLmstatInfo returns lmstat binary information.
 func (c *lmstatCollector) getLmstatInfo(ch chan<- prometheus.Metric) error {
-	outBytes, err := lmutilOutput(c.logger, "lmstat", "-v")
+	outBytes, err := lmutilOutput(c.logger, "", "lmstat", "-v")
 	if err != nil {
 		return err
 	}
 
-	outStr, err := splitOutput(outBytes)
+	doc, err := parseLmstatOutput(outBytes, "", c.logger)
 	if err != nil {
 		return err
 	}
 
-	lmstatInfo := parseLmstatVersion(outStr)
+	lmstatInfo := parseLmstatVersion(doc)
 
 	ch <- prometheus.MustNewConstMetric(c.lmstatInfo, prometheus.GaugeValue, 1.0, lmstatInfo.arch, lmstatInfo.build, lmstatInfo.version)
 
@@ -517,11 +551,11 @@ func (c *lmstatCollector) getLmstatLicensesInfo(ch chan<- prometheus.Metric) err
 		go func(licenses config.License) {
 			defer wg.Done()
 
-			if err := c.collect(&licenses, ch); err == nil {
-				ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, 0, "lmstat", licenses.Name)
-			} else {
-				ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, 1, "lmstat", licenses.Name)
-			}
+			begin := time.Now()
+			err := withBackoff("lmstat", licenses.Name, c.logger, func() error {
+				return c.collect(&licenses, ch)
+			})
+			sendLicenseScrape(ch, "lmstat", licenses.Name, begin, err)
 		}(licenses)
 	}
 
@@ -529,34 +563,15 @@ func (c *lmstatCollector) getLmstatLicensesInfo(ch chan<- prometheus.Metric) err
 }
 
 func (c *lmstatCollector) collect(licenses *config.License, ch chan<- prometheus.Metric) error {
-	var (
-		outBytes []byte
-		err      error
-	)
-
-	// Call lmstat with -a (display everything)
-	switch {
-	case licenses.LicenseFile != "":
-		outBytes, err = lmutilOutput(c.logger, "lmstat", "-c", licenses.LicenseFile, "-a")
-		if err != nil {
-			return err
-		}
-	case licenses.LicenseServer != "":
-		outBytes, err = lmutilOutput(c.logger, "lmstat", "-c", licenses.LicenseServer, "-a")
-		if err != nil {
-			return err
-		}
-	default:
-		return fmt.Errorf("couldn't find `license_file` or `license_server` for %v",
-			licenses.Name)
-	}
-
-	outStr, err := splitOutput(outBytes)
+	doc, err := c.lmstatLicenseOutput(licenses)
 	if err != nil {
 		return err
 	}
 
-	servers := parseLmstatLicenseInfoServer(outStr)
+	ch <- prometheus.MustNewConstMetric(unparsedLinesDesc, prometheus.GaugeValue,
+		float64(doc.Unparsed(lmstat.SectionFeatures)), licenses.Name, "lmstat")
+
+	servers := parseLmstatLicenseInfoServer(doc)
 	for _, info := range servers {
 		if info.status {
 			ch <- prometheus.MustNewConstMetric(c.lmstatServerStatus,
@@ -569,7 +584,7 @@ func (c *lmstatCollector) collect(licenses *config.License, ch chan<- prometheus
 		}
 	}
 
-	vendors := parseLmstatLicenseInfoVendor(outStr)
+	vendors := parseLmstatLicenseInfoVendor(doc)
 	for name, info := range vendors {
 		if info.status {
 			ch <- prometheus.MustNewConstMetric(c.lmstatVendorStatus,
@@ -581,22 +596,17 @@ func (c *lmstatCollector) collect(licenses *config.License, ch chan<- prometheus
 		}
 	}
 	// features
-	var (
-		featuresToExclude = []string{}
-		featuresToInclude = []string{}
-	)
-
-	switch {
-	case licenses.FeaturesToExclude != "" && licenses.FeaturesToInclude != "":
-		return fmt.Errorf("%v: can not define `features_to_include` and "+
-			"`features_to_exclude` at the same time", licenses.Name)
-	case licenses.FeaturesToExclude != "":
-		featuresToExclude = strings.Split(licenses.FeaturesToExclude, ",")
-	case licenses.FeaturesToInclude != "":
-		featuresToInclude = strings.Split(licenses.FeaturesToInclude, ",")
+	featuresToExclude, featuresToInclude, err := featuresFilter(licenses)
+	if err != nil {
+		return err
 	}
 
-	features, licUsersByFeature, reservGroupByFeature, reservHostByFeature := parseLmstatLicenseInfoFeature(outStr, c.logger)
+	now := time.Now()
+
+	features, licUsersByFeature, reservGroupByFeature, reservHostByFeature := parseLmstatLicenseInfoFeature(doc, c.logger)
+	pools := parseLmstatLicenseInfoPools(doc, c.logger)
+	reservations := parseLmstatLicenseInfoReservations(doc)
+
 	for name, info := range features {
 		if contains(featuresToExclude, name) {
 			continue
@@ -608,6 +618,23 @@ func (c *lmstatCollector) collect(licenses *config.License, ch chan<- prometheus
 		ch <- prometheus.MustNewConstMetric(c.lmstatFeatureIssued,
 			prometheus.GaugeValue, info.issued, licenses.Name, name, info.licenseType)
 
+		for pool, used := range pools[name] {
+			ch <- prometheus.MustNewConstMetric(c.lmstatFeaturePoolUsed,
+				prometheus.GaugeValue, used, licenses.Name, name, pool.name, pool.version, pool.expiry)
+		}
+
+		if c.peaksEnabled() {
+			c.peaks.observe(licenses.Name, name, info.used, now)
+
+			if minUsed, maxUsed, ok := c.peaks.minMax(licenses.Name, name, now); ok {
+				ch <- prometheus.MustNewConstMetric(c.lmstatFeatureUsedMax,
+					prometheus.GaugeValue, maxUsed, licenses.Name, name)
+
+				ch <- prometheus.MustNewConstMetric(c.lmstatFeatureUsedMin,
+					prometheus.GaugeValue, minUsed, licenses.Name, name)
+			}
+		}
+
 		var sumByType float64
 		for _, typeUsed := range info.usedByType {
 			sumByType += typeUsed
@@ -663,7 +690,277 @@ func (c *lmstatCollector) collect(licenses *config.License, ch chan<- prometheus
 					licreserv, licenses.Name, name, host)
 			}
 		}
+
+		if licenses.MonitorReservations {
+			for reservation, licreserv := range reservations[name] {
+				ch <- prometheus.MustNewConstMetric(
+					c.lmstatFeatureReserved, prometheus.GaugeValue,
+					licreserv, licenses.Name, name, reservation.targetType, reservation.target)
+			}
+		}
+	}
+
+	c.peaks.reset(licenses.Name)
+
+	filtered := func(name string) bool {
+		return contains(featuresToExclude, name) ||
+			(licenses.FeaturesToInclude != "" && !contains(featuresToInclude, name))
+	}
+
+	var options *optionsFile
+
+	if licenses.OptionsFile != "" {
+		options, err = readOptionsFile(licenses.OptionsFile, c.logger)
+		if err != nil {
+			c.logger.Warn("couldn't read options file", "app", licenses.Name, "err", err)
+			options = nil
+		} else {
+			c.collectOptionsFile(licenses, options, features, filtered, ch)
+		}
+	}
+
+	packages := parseLmstatLicenseInfoPackages(doc)
+	if isLicenseFileReadable(licenses.LicenseFile) {
+		lf, err := readLicenseFile(licenses.LicenseFile, c.logger)
+		if err != nil {
+			c.logger.Warn("couldn't read license file packages", "app", licenses.Name, "err", err)
+			lf = &licenseFile{}
+		}
+
+		for _, p := range lf.packages {
+			for _, component := range p.components {
+				if packages[p.name] == nil {
+					packages[p.name] = map[string]bool{}
+				}
+
+				packages[p.name][component] = true
+			}
+		}
+	}
+
+	for name, components := range packages {
+		for component := range components {
+			if contains(featuresToExclude, component) ||
+				(licenses.FeaturesToInclude != "" && !contains(featuresToInclude, component)) {
+				continue
+			}
+
+			ch <- prometheus.MustNewConstMetric(c.lmstatPackageInfo,
+				prometheus.GaugeValue, 1.0, licenses.Name, name, component)
+		}
+	}
+
+	sessionsByFeature := parseLmstatLicenseInfoSessions(doc, c.logger)
+	for name := range sessionsByFeature {
+		if filtered(name) {
+			delete(sessionsByFeature, name)
+		}
+	}
+
+	if licenses.MonitorReservations {
+		c.collectReservationUsage(licenses, options, reservations, sessionsByFeature, filtered, ch)
+	}
+
+	c.sessions.observe(licenses.Name, features, sessionsByFeature, now)
+
+	return nil
+}
+
+// lmstatQueries returns the lmstat options to get the feature usage of a
+// license. lmstat -f for each included feature is the cheapest when there are
+// only a few of them, otherwise lmstat -S when the vendor is set, otherwise
+// lmstat -a.
+func lmstatQueries(licenses *config.License, maxFeatureQueries int) [][]string {
+	var features []string
+
+	if licenses.FeaturesToInclude != "" && licenses.FeaturesToExclude == "" {
+		for _, name := range strings.Split(licenses.FeaturesToInclude, ",") {
+			if name != "" && !contains(features, name) {
+				features = append(features, name)
+			}
+		}
+	}
+
+	switch {
+	case len(features) > 0 && len(features) <= maxFeatureQueries:
+		queries := make([][]string, 0, len(features))
+		for _, name := range features {
+			queries = append(queries, []string{"-f", name})
+		}
+
+		return queries
+	case licenses.Vendor != "":
+		return [][]string{{"-S", licenses.Vendor}}
+	default:
+		return [][]string{{"-a"}}
 	}
+}
+
+// mergeLmstatDocuments returns the servers and vendors status of lmstat
+// without options, with the features of the lmstat -f or -S outputs.
+func mergeLmstatDocuments(status *lmstat.Document, docs []*lmstat.Document) *lmstat.Document {
+	merged := &lmstat.Document{
+		Header:      status.Header,
+		Servers:     status.Servers,
+		Vendors:     status.Vendors,
+		Diagnostics: status.Diagnostics,
+	}
+
+	for _, doc := range docs {
+		merged.Features = append(merged.Features, doc.Features...)
+		merged.Diagnostics = append(merged.Diagnostics, doc.Diagnostics...)
+	}
+
+	return merged
+}
+
+// lmstatLicenseOutput calls lmstat with -a (display everything) for a license,
+// or restricted to the included features or the vendor, see lmstatQueries.
+func (c *lmstatCollector) lmstatLicenseOutput(licenses *config.License) (*lmstat.Document, error) {
+	target, err := licenseTarget(licenses)
+	if err != nil {
+		return nil, err
+	}
+
+	lmstatOutput := func(options ...string) (*lmstat.Document, error) {
+		outBytes, err := lmutilOutput(c.logger, licenses.Name, append([]string{"lmstat", "-c", target}, options...)...)
+		if err != nil {
+			return nil, err
+		}
+
+		return parseLmstatOutput(outBytes, licenses.Name, c.logger)
+	}
+
+	queries := lmstatQueries(licenses, *lmstatMaxFeatureQueries)
+	if len(queries) == 1 && queries[0][0] == "-a" {
+		return lmstatOutput("-a")
+	}
+
+	status, err := lmstatOutput()
+	if err != nil {
+		return nil, err
+	}
+
+	docs := make([]*lmstat.Document, 0, len(queries))
+
+	for _, query := range queries {
+		doc, err := lmstatOutput(query...)
+		if err != nil {
+			return nil, err
+		}
+
+		docs = append(docs, doc)
+	}
+
+	return mergeLmstatDocuments(status, docs), nil
+}
+
+// licenseTarget returns the license file or the license server of a license,
+// as passed to lmstat -c.
+func licenseTarget(licenses *config.License) (string, error) {
+	switch {
+	case licenses.LicenseFile != "":
+		return licenses.LicenseFile, nil
+	case licenses.LicenseServer != "":
+		return licenses.LicenseServer, nil
+	default:
+		return "", fmt.Errorf("couldn't find `license_file` or `license_server` for %v", licenses.Name)
+	}
+}
+
+// featuresFilter returns the features to exclude and to include of a license.
+func featuresFilter(licenses *config.License) (featuresToExclude, featuresToInclude []string, err error) {
+	switch {
+	case licenses.FeaturesToExclude != "" && licenses.FeaturesToInclude != "":
+		return nil, nil, fmt.Errorf("%v: can not define `features_to_include` and "+
+			"`features_to_exclude` at the same time", licenses.Name)
+	case licenses.FeaturesToExclude != "":
+		featuresToExclude = strings.Split(licenses.FeaturesToExclude, ",")
+	case licenses.FeaturesToInclude != "":
+		featuresToInclude = strings.Split(licenses.FeaturesToInclude, ",")
+	}
+
+	return featuresToExclude, featuresToInclude, nil
+}
+
+// peaksEnabled returns whether peak usage is tracked.
+func (c *lmstatCollector) peaksEnabled() bool {
+	return *lmstatPollInterval > 0 || *lmstatPeakWindow > 0
+}
+
+// pollLicenses polls lmstat in the background every --collector.lmstat.poll-interval
+// until ctx is canceled, so usage peaks and short sessions between two scrapes
+// are not missed.
+func (c *lmstatCollector) pollLicenses(ctx context.Context) {
+	if *lmstatPollInterval <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(*lmstatPollInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		wg := &sync.WaitGroup{}
+
+		for _, licenses := range LicenseConfig.Licenses {
+			wg.Add(lenghtOne)
+
+			go func(licenses config.License) {
+				defer wg.Done()
+
+				err := withBackoff("lmstat", licenses.Name, c.logger, func() error {
+					return c.poll(&licenses)
+				})
+				if err != nil {
+					c.logger.Debug("couldn't poll license", "app", licenses.Name, "err", err)
+				}
+			}(licenses)
+		}
+
+		wg.Wait()
+	}
+}
+
+// poll records the feature usage and sessions of a license without exporting
+// any metric.
+func (c *lmstatCollector) poll(licenses *config.License) error {
+	doc, err := c.lmstatLicenseOutput(licenses)
+	if err != nil {
+		return err
+	}
+
+	featuresToExclude, featuresToInclude, err := featuresFilter(licenses)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	filtered := func(name string) bool {
+		return contains(featuresToExclude, name) ||
+			(licenses.FeaturesToInclude != "" && !contains(featuresToInclude, name))
+	}
+
+	features, _, _, _ := parseLmstatLicenseInfoFeature(doc, c.logger)
+	for name, info := range features {
+		if !filtered(name) {
+			c.peaks.observe(licenses.Name, name, info.used, now)
+		}
+	}
+
+	sessionsByFeature := parseLmstatLicenseInfoSessions(doc, c.logger)
+	for name := range sessionsByFeature {
+		if filtered(name) {
+			delete(sessionsByFeature, name)
+		}
+	}
+
+	c.sessions.observe(licenses.Name, features, sessionsByFeature, now)
 
 	return nil
 }
@@ -23,7 +23,9 @@ import (
 	"sync"
 	"time"
 
+	kingpin "github.com/alecthomas/kingpin/v2"
 	"github.com/mjtrangoni/flexlm_exporter/config"
+	"github.com/mjtrangoni/flexlm_exporter/lmstat"
 	"github.com/prometheus/client_golang/prometheus"
 	"golang.org/x/text/cases"
 	"golang.org/x/text/language"
@@ -33,12 +35,38 @@ const (
 	lenghtOne   = 1
 	posInfinity = 1
 	yearLength  = 4
+
+	permanentString = "permanent"
+
+	hoursPerDay = 24
+	day         = hoursPerDay * time.Hour
 )
 
+var lmstatFeatureExpLegacyLabels = kingpin.Flag("collector.lmstat_feature_exp.legacy-labels",
+	"Label the expiration dates by the index of the lmstat -i line and the number of licenses, as in previous versions.").
+	Default("false").Bool()
+
+var lmstatFeatureExpWindows = kingpin.Flag("collector.lmstat_feature_exp.window",
+	"Window of the features expiring, in days like 30d or as a duration, can be repeated.").
+	Default("7d", "14d", "30d", "90d").Strings()
+
 type lmstatFeatureExpCollector struct {
-	lmstatFeatureExp     *prometheus.Desc
-	lmstatFeatureAggrExp *prometheus.Desc
-	logger               *slog.Logger
+	lmstatFeatureExp               *prometheus.Desc
+	lmstatFeatureExpLicenses       *prometheus.Desc
+	lmstatFeatureAggrExp           *prometheus.Desc
+	lmstatFeatureAggrExpLicenses   *prometheus.Desc
+	lmstatFeatureAggrExpFeatures   *prometheus.Desc
+	lmstatFeatureExpLegacy         *prometheus.Desc
+	lmstatFeatureAggrExpLegacy     *prometheus.Desc
+	lmstatFeatureExpiresInDays     *prometheus.Desc
+	lmstatFeaturesExpiring         *prometheus.Desc
+	lmstatFeaturesExpiringLicenses *prometheus.Desc
+	lmstatFeaturesExpired          *prometheus.Desc
+	lmstatFeaturesExpiredLicenses  *prometheus.Desc
+	lmstatFeaturePermanent         *prometheus.Desc
+	windows                        []expiryWindow
+	renewals                       *renewalTracker
+	logger                         *slog.Logger
 }
 
 func init() {
@@ -49,8 +77,54 @@ func init() {
 // NewLmstatFeatureExpCollector returns a new Collector exposing lmstat license
 // feature expiration date.
 func NewLmstatFeatureExpCollector(logger *slog.Logger) (Collector, error) {
+	windows := make([]expiryWindow, 0, len(*lmstatFeatureExpWindows))
+
+	for _, window := range *lmstatFeatureExpWindows {
+		d, err := parseExpiryWindow(window)
+		if err != nil {
+			return nil, err
+		}
+
+		windows = append(windows, expiryWindow{label: window, duration: d})
+	}
+
+	renewals, err := newRenewalTracker(*lmstatFeatureExpRenewalsState, logger)
+	if err != nil {
+		return nil, err
+	}
+
 	return &lmstatFeatureExpCollector{
 		lmstatFeatureExp: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature",
+				"expiration_seconds"),
+			"License feature expiration date in seconds labeled by app, name, vendor, version, expiry.",
+			[]string{
+				appString, nameString, "vendor", versionString, "expiry",
+			}, nil,
+		),
+		lmstatFeatureExpLicenses: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature", "expiration_licenses"),
+			"License feature licenses expiring labeled by app, name, vendor, version, expiry.",
+			[]string{
+				appString, nameString, "vendor", versionString, "expiry",
+			}, nil,
+		),
+		lmstatFeatureAggrExp: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature", "aggregate_expiration_seconds"),
+			"Aggregate by license features expiration day in seconds. Labeled by app, expiry.",
+			[]string{appString, "expiry"}, nil,
+		),
+		lmstatFeatureAggrExpLicenses: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature", "aggregate_expiration_licenses"),
+			"Aggregate by license features expiration day licenses expiring. Labeled by app, expiry.",
+			[]string{appString, "expiry"}, nil,
+		),
+		lmstatFeatureAggrExpFeatures: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature", "aggregate_expiration_features"),
+			"Aggregate by license features expiration day features expiring. Labeled by app, expiry.",
+			[]string{appString, "expiry"}, nil,
+		),
+		lmstatFeatureExpLegacy: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "feature",
 				"expiration_seconds"),
 			"License feature expiration date in seconds labeled by app, name, index, licenses, vendor, version.",
@@ -58,12 +132,46 @@ func NewLmstatFeatureExpCollector(logger *slog.Logger) (Collector, error) {
 				appString, nameString, "index", "licenses", "vendor", versionString,
 			}, nil,
 		),
-		lmstatFeatureAggrExp: prometheus.NewDesc(
+		lmstatFeatureAggrExpLegacy: prometheus.NewDesc(
 			prometheus.BuildFQName(namespace, "feature", "aggregate_expiration_seconds"),
 			"Aggregate by license features expiration day in seconds. Labeled by app, licenses, features.",
 			[]string{appString, "index", "licenses", "features"}, nil,
 		),
-		logger: logger,
+		lmstatFeatureExpiresInDays: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature", "expires_in_days"),
+			"Days left before the license feature expires, negative once expired, labeled by app, name, vendor, version, expiry.",
+			[]string{
+				appString, nameString, "vendor", versionString, "expiry",
+			}, nil,
+		),
+		lmstatFeaturesExpiring: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "features", "expiring"),
+			"Number of license features expiring within the window labeled by app, window.",
+			[]string{appString, "window"}, nil,
+		),
+		lmstatFeaturesExpiringLicenses: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "features", "expiring_licenses"),
+			"Number of licenses expiring within the window labeled by app, window.",
+			[]string{appString, "window"}, nil,
+		),
+		lmstatFeaturesExpired: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "features", "expired"),
+			"Number of license features already expired labeled by app.",
+			[]string{appString}, nil,
+		),
+		lmstatFeaturesExpiredLicenses: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "features", "expired_licenses"),
+			"Number of licenses already expired labeled by app.",
+			[]string{appString}, nil,
+		),
+		lmstatFeaturePermanent: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature", "permanent_info"),
+			"Permanent license feature labeled by app, name, vendor, version.",
+			[]string{appString, nameString, "vendor", versionString}, nil,
+		),
+		windows:  windows,
+		renewals: renewals,
+		logger:   logger,
 	}, nil
 }
 
@@ -75,85 +183,74 @@ func (c *lmstatFeatureExpCollector) Update(ch chan<- prometheus.Metric) error {
 		return fmt.Errorf("couldn't get licenses feature expiration date: %w", err)
 	}
 
+	c.renewals.collect(ch)
+
 	return nil
 }
 
-func parseLmstatLicenseFeatureExpDate(outStr [][]string, logger *slog.Logger) map[int]*featureExp {
-	var (
-		expires     float64
-		index       int
-		expIndex    int
-		vendorIndex int
-		matches     []string
-	)
-
+func parseLmstatLicenseFeatureExpDate(doc *lmstat.Document, logger *slog.Logger) map[int]*featureExp {
 	featuresExp := make(map[int]*featureExp)
-	// iterate over output lines
-	for _, line := range outStr {
-		lineJoined := strings.Join(line, "")
 
-		switch {
-		case (!lmutilLicenseFeatureExpRegex.MatchString(lineJoined) &&
-			!lmutilLicenseFeatureExpRegex2.MatchString(lineJoined)):
-			continue
-		case lmutilLicenseFeatureExpRegex.MatchString(lineJoined):
-			matches = lmutilLicenseFeatureExpRegex.FindStringSubmatch(lineJoined)
-			expIndex = 4
-			vendorIndex = 5
-		default:
-			matches = lmutilLicenseFeatureExpRegex2.FindStringSubmatch(lineJoined)
-			expIndex = 5
-			vendorIndex = 4
+	for index, e := range doc.Expirations {
+		featuresExp[index+1] = &featureExp{
+			name:     e.Feature,
+			expires:  parseExpirationDate(e.Expires, logger),
+			licenses: strconv.Itoa(e.Licenses),
+			vendor:   e.Vendor,
+			version:  e.Version,
 		}
+	}
 
-		logger.Debug("debug", "matches", matches)
-		// Parse date, month has to be capitalized.
-		slice := strings.Split(matches[expIndex], "-")
-		if len(slice) > lenghtOne {
-			day, month, year := slice[0], slice[1], slice[2]
-			if len(year) > yearLength {
-				lenToRemove := len(year) - yearLength
-				year = year[:len(year)-lenToRemove]
-			}
+	return featuresExp
+}
 
-			if len(day) == lenghtOne {
-				day = "0" + day
-			}
+// parseExpirationDate parses a FLEXlm expiration date, like 31-dec-2018, in
+// seconds. Permanent licenses expire at +Inf.
+func parseExpirationDate(date string, logger *slog.Logger) float64 {
+	// Parse date, month has to be capitalized.
+	slice := strings.Split(date, "-")
+	if len(slice) <= lenghtOne {
+		// every string matching the expiration position will be considered
+		// as permanent
+		return math.Inf(posInfinity)
+	}
 
-			if len(year) == lenghtOne {
-				year = "000" + year
-			}
+	day, month, year := slice[0], slice[1], slice[2]
+	if len(year) > yearLength {
+		lenToRemove := len(year) - yearLength
+		year = year[:len(year)-lenToRemove]
+	}
 
-			expireDate, err := time.Parse("02-Jan-2006",
-				fmt.Sprintf("%s-%s-%s", day,
-					cases.Title(language.English).String(month), year))
-			if err != nil {
-				logger.Error("err", "could not convert to date:", err)
-			}
+	if len(day) == lenghtOne {
+		day = "0" + day
+	}
 
-			if expireDate.Unix() <= 0 {
-				expires = math.Inf(posInfinity)
-			} else {
-				expires = float64(expireDate.Unix())
-			}
-		} else {
-			// every string matching the expiration position will be considered
-			// as permanent
-			expires = math.Inf(posInfinity)
-		}
+	if len(year) == lenghtOne {
+		year = "000" + year
+	}
 
-		index++
+	expireDate, err := time.Parse("02-Jan-2006",
+		fmt.Sprintf("%s-%s-%s", day,
+			cases.Title(language.English).String(month), year))
+	if err != nil {
+		logger.Error("err", "could not convert to date:", err)
+	}
 
-		featuresExp[index] = &featureExp{
-			name:     matches[1],
-			expires:  expires,
-			licenses: matches[3],
-			vendor:   matches[vendorIndex],
-			version:  matches[2],
-		}
+	if expireDate.Unix() <= 0 {
+		return math.Inf(posInfinity)
 	}
 
-	return featuresExp
+	return float64(expireDate.Unix())
+}
+
+// dateLabel returns a date in seconds as label value, in the 2006-01-02
+// format, or permanent.
+func dateLabel(expires float64) string {
+	if math.IsInf(expires, posInfinity) {
+		return permanentString
+	}
+
+	return time.Unix(int64(expires), 0).UTC().Format(time.DateOnly)
 }
 
 // getLmstatFeatureExpDate returns lmstat active and inactive licenses expiration date.
@@ -167,11 +264,11 @@ func (c *lmstatFeatureExpCollector) getLmstatFeatureExpDate(ch chan<- prometheus
 		go func(licenses config.License) {
 			defer wg.Done()
 
-			if err := c.collect(&licenses, ch); err == nil {
-				ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, 0, "lmstat_feature_exp", licenses.Name)
-			} else {
-				ch <- prometheus.MustNewConstMetric(scrapeErrorDesc, prometheus.GaugeValue, 1, "lmstat_feature_exp", licenses.Name)
-			}
+			begin := time.Now()
+			err := withBackoff("lmstat_feature_exp", licenses.Name, c.logger, func() error {
+				return c.collect(&licenses, ch)
+			})
+			sendLicenseScrape(ch, "lmstat_feature_exp", licenses.Name, begin, err)
 		}(licenses)
 	}
 
@@ -179,50 +276,213 @@ func (c *lmstatFeatureExpCollector) getLmstatFeatureExpDate(ch chan<- prometheus
 }
 
 func (c *lmstatFeatureExpCollector) collect(licenses *config.License, ch chan<- prometheus.Metric) error {
-	var (
-		outBytes []byte
-		err      error
-	)
+	target, err := licenseTarget(licenses)
+	if err != nil {
+		return err
+	}
+
 	// Call lmstat with -i (lmstat -i does not give information from the server,
 	// but only reads the license file)
+	outBytes, err := lmutilOutput(c.logger, licenses.Name, "lmstat", "-c", target, "-i")
+	if err != nil {
+		return err
+	}
 
-	switch {
-	case licenses.LicenseFile != "":
-		outBytes, err = lmutilOutput(c.logger, "lmstat", "-c", licenses.LicenseFile, "-i")
-		if err != nil {
-			return err
-		}
-	case licenses.LicenseServer != "":
-		outBytes, err = lmutilOutput(c.logger, "lmstat", "-c", licenses.LicenseServer, "-i")
-		if err != nil {
-			return err
-		}
-	default:
-		return fmt.Errorf("couldn't find `license_file` or `license_server` for %v", licenses.Name)
+	doc, err := parseLmstatOutput(outBytes, licenses.Name, c.logger)
+	if err != nil {
+		return err
 	}
 
-	outStr, err := splitOutput(outBytes)
+	ch <- prometheus.MustNewConstMetric(unparsedLinesDesc, prometheus.GaugeValue,
+		float64(doc.Unparsed(lmstat.SectionExpirations)), licenses.Name, "lmstat_feature_exp")
+
+	featuresToExclude, featuresToInclude, err := featuresFilter(licenses)
 	if err != nil {
 		return err
 	}
 
-	// features
-	var (
-		featuresToExclude = []string{}
-		featuresToInclude = []string{}
-	)
-
-	switch {
-	case licenses.FeaturesToExclude != "" && licenses.FeaturesToInclude != "":
-		return fmt.Errorf("%v: can not define `features_to_include` and "+
-			"`features_to_exclude` at the same time", licenses.Name)
-	case licenses.FeaturesToExclude != "":
-		featuresToExclude = strings.Split(licenses.FeaturesToExclude, ",")
-	case licenses.FeaturesToInclude != "":
-		featuresToInclude = strings.Split(licenses.FeaturesToInclude, ",")
+	featuresExp := parseLmstatLicenseFeatureExpDate(doc, c.logger)
+	included := make([]*featureExp, 0, len(featuresExp))
+
+	for _, feature := range featuresExp {
+		if contains(featuresToExclude, feature.name) {
+			continue
+		} else if licenses.FeaturesToInclude != "" &&
+			!contains(featuresToInclude, feature.name) {
+			continue
+		}
+
+		included = append(included, feature)
+	}
+
+	now := time.Now()
+	pools := mergeFeaturesExp(included)
+	c.collectExpiryWindows(licenses, pools, now, ch)
+	c.renewals.observe(licenses.Name, pools, now)
+
+	if *lmstatFeatureExpLegacyLabels {
+		c.collectLegacy(licenses, featuresExp, featuresToExclude, featuresToInclude, ch)
+		return nil
+	}
+
+	aggrFeaturesExpMap := make(map[string]*aggrFeaturesExp)
+
+	for key, pool := range pools {
+		ch <- prometheus.MustNewConstMetric(c.lmstatFeatureExp, prometheus.GaugeValue, pool.expires,
+			licenses.Name, key.name, key.vendor, key.version, key.expiry)
+		ch <- prometheus.MustNewConstMetric(c.lmstatFeatureExpLicenses, prometheus.GaugeValue, float64(pool.licenses),
+			licenses.Name, key.name, key.vendor, key.version, key.expiry)
+
+		if val, ok := aggrFeaturesExpMap[key.expiry]; ok {
+			val.licenses += pool.licenses
+			val.features++
+		} else {
+			aggrFeaturesExpMap[key.expiry] = &aggrFeaturesExp{
+				app:      licenses.Name,
+				expires:  pool.expires,
+				features: lenghtOne,
+				licenses: pool.licenses,
+			}
+		}
 	}
 
-	featuresExp := parseLmstatLicenseFeatureExpDate(outStr, c.logger)
+	for expiry, val := range aggrFeaturesExpMap {
+		ch <- prometheus.MustNewConstMetric(c.lmstatFeatureAggrExp, prometheus.GaugeValue, val.expires,
+			val.app, expiry)
+		ch <- prometheus.MustNewConstMetric(c.lmstatFeatureAggrExpLicenses, prometheus.GaugeValue, float64(val.licenses),
+			val.app, expiry)
+		ch <- prometheus.MustNewConstMetric(c.lmstatFeatureAggrExpFeatures, prometheus.GaugeValue, float64(val.features),
+			val.app, expiry)
+	}
+
+	return nil
+}
+
+// mergeFeaturesExp merges the lmstat -i lines of the same feature, vendor,
+// version and expiry date, summing their licenses.
+func mergeFeaturesExp(featuresExp []*featureExp) map[featureExpKey]*featureExpPool {
+	pools := make(map[featureExpKey]*featureExpPool)
+
+	for _, feature := range featuresExp {
+		licenseCount, _ := strconv.Atoi(feature.licenses)
+		key := featureExpKey{
+			name:    feature.name,
+			vendor:  feature.vendor,
+			version: feature.version,
+			expiry:  dateLabel(feature.expires),
+		}
+
+		if pool, ok := pools[key]; ok {
+			pool.licenses += licenseCount
+		} else {
+			pools[key] = &featureExpPool{expires: feature.expires, licenses: licenseCount}
+		}
+	}
+
+	return pools
+}
+
+// parseExpiryWindow parses an expiry window, in days like 30d, or as a
+// duration like 720h.
+func parseExpiryWindow(window string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(window, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n <= 0 {
+			return 0, fmt.Errorf("invalid expiry window %q", window)
+		}
+
+		return time.Duration(n) * day, nil
+	}
+
+	d, err := time.ParseDuration(window)
+	if err != nil || d <= 0 {
+		return 0, fmt.Errorf("invalid expiry window %q", window)
+	}
+
+	return d, nil
+}
+
+// countExpiring counts the features and licenses expiring within each window,
+// and the ones already expired. A feature with several pools in a window is
+// counted once, its licenses are summed. Permanent features never expire.
+func countExpiring(pools map[featureExpKey]*featureExpPool, windows []expiryWindow,
+	now time.Time) (expiring []expiryCount, expired expiryCount) {
+	expiring = make([]expiryCount, len(windows))
+	expiringNames := make([]map[string]bool, len(windows))
+	expiredNames := make(map[string]bool)
+
+	for i := range windows {
+		expiringNames[i] = make(map[string]bool)
+	}
+
+	for key, pool := range pools {
+		if math.IsInf(pool.expires, posInfinity) {
+			continue
+		}
+
+		left := time.Unix(int64(pool.expires), 0).Sub(now)
+		if left <= 0 {
+			expiredNames[key.name] = true
+			expired.licenses += pool.licenses
+
+			continue
+		}
+
+		for i, w := range windows {
+			if left <= w.duration {
+				expiringNames[i][key.name] = true
+				expiring[i].licenses += pool.licenses
+			}
+		}
+	}
+
+	for i := range windows {
+		expiring[i].features = len(expiringNames[i])
+	}
+
+	expired.features = len(expiredNames)
+
+	return expiring, expired
+}
+
+// collectExpiryWindows sends the days left before each feature expires, the
+// features and licenses expiring within each window or already expired, and
+// the permanent features.
+func (c *lmstatFeatureExpCollector) collectExpiryWindows(licenses *config.License,
+	pools map[featureExpKey]*featureExpPool, now time.Time, ch chan<- prometheus.Metric) {
+	for key, pool := range pools {
+		if math.IsInf(pool.expires, posInfinity) {
+			ch <- prometheus.MustNewConstMetric(c.lmstatFeaturePermanent, prometheus.GaugeValue, 1.0,
+				licenses.Name, key.name, key.vendor, key.version)
+
+			continue
+		}
+
+		left := time.Unix(int64(pool.expires), 0).Sub(now)
+		ch <- prometheus.MustNewConstMetric(c.lmstatFeatureExpiresInDays, prometheus.GaugeValue, left.Hours()/hoursPerDay,
+			licenses.Name, key.name, key.vendor, key.version, key.expiry)
+	}
+
+	expiring, expired := countExpiring(pools, c.windows, now)
+
+	for i, w := range c.windows {
+		ch <- prometheus.MustNewConstMetric(c.lmstatFeaturesExpiring, prometheus.GaugeValue,
+			float64(expiring[i].features), licenses.Name, w.label)
+		ch <- prometheus.MustNewConstMetric(c.lmstatFeaturesExpiringLicenses, prometheus.GaugeValue,
+			float64(expiring[i].licenses), licenses.Name, w.label)
+	}
+
+	ch <- prometheus.MustNewConstMetric(c.lmstatFeaturesExpired, prometheus.GaugeValue,
+		float64(expired.features), licenses.Name)
+	ch <- prometheus.MustNewConstMetric(c.lmstatFeaturesExpiredLicenses, prometheus.GaugeValue,
+		float64(expired.licenses), licenses.Name)
+}
+
+// collectLegacy sends the expiration dates labeled by the index of the lmstat
+// -i line and the number of licenses, as before
+// --collector.lmstat_feature_exp.legacy-labels was introduced.
+func (c *lmstatFeatureExpCollector) collectLegacy(licenses *config.License, featuresExp map[int]*featureExp,
+	featuresToExclude, featuresToInclude []string, ch chan<- prometheus.Metric) {
 	aggrFeaturesExpMap := make(map[float64]*aggrFeaturesExp)
 
 	for idx, feature := range featuresExp {
@@ -245,7 +505,7 @@ func (c *lmstatFeatureExpCollector) collect(licenses *config.License, ch chan<-
 			}
 		}
 
-		ch <- prometheus.MustNewConstMetric(c.lmstatFeatureExp,
+		ch <- prometheus.MustNewConstMetric(c.lmstatFeatureExpLegacy,
 			prometheus.GaugeValue, feature.expires,
 			licenses.Name, feature.name, strconv.Itoa(idx),
 			feature.licenses, feature.vendor,
@@ -262,10 +522,8 @@ func (c *lmstatFeatureExpCollector) collect(licenses *config.License, ch chan<-
 
 	for idx, exp := range aggrFeaturesKeys {
 		val := aggrFeaturesExpMap[exp]
-		ch <- prometheus.MustNewConstMetric(c.lmstatFeatureAggrExp,
+		ch <- prometheus.MustNewConstMetric(c.lmstatFeatureAggrExpLegacy,
 			prometheus.GaugeValue, exp,
 			val.app, strconv.Itoa(idx), strconv.Itoa(val.licenses), strconv.Itoa(val.features))
 	}
-
-	return nil
 }
@@ -15,10 +15,13 @@
 package collector
 
 import (
+	"bytes"
 	"math"
 	"os"
 	"testing"
+	"time"
 
+	"github.com/mjtrangoni/flexlm_exporter/lmstat"
 	"github.com/prometheus/common/promslog"
 )
 
@@ -38,13 +41,13 @@ func TestParseLmstatLicenseFeatureExpDate1(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err := splitOutput(dataByte)
+	doc, err := lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	logger := promslog.New(&promslog.Config{})
-	featuresExp := parseLmstatLicenseFeatureExpDate(dataStr, logger)
+	featuresExp := parseLmstatLicenseFeatureExpDate(doc, logger)
 	found := false
 
 	for index, feature := range featuresExp {
@@ -117,13 +120,13 @@ func TestParseLmstatLicenseFeatureExpDate2(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err := splitOutput(dataByte)
+	doc, err := lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	logger := promslog.New(&promslog.Config{})
-	featuresExp := parseLmstatLicenseFeatureExpDate(dataStr, logger)
+	featuresExp := parseLmstatLicenseFeatureExpDate(doc, logger)
 	found := false
 
 	for index, feature := range featuresExp {
@@ -156,3 +159,113 @@ func TestParseLmstatLicenseFeatureExpDate2(t *testing.T) {
 		t.Fatalf("feature_name_9 not found")
 	}
 }
+
+func TestMergeFeaturesExp(t *testing.T) {
+	t.Parallel()
+
+	pools := mergeFeaturesExp([]*featureExp{
+		{name: feature12String, expires: 1546214400, licenses: "50", vendor: vendor2String, version: v201812String},
+		{name: feature12String, expires: 1538265600, licenses: "2", vendor: vendor2String, version: v201812String},
+		{name: feature12String, expires: 1546214400, licenses: "10", vendor: vendor2String, version: v201812String},
+		{name: "feature15", expires: math.Inf(posInfinity), licenses: "2", vendor: vendor2String, version: "2018.09"},
+	})
+
+	for key, want := range map[featureExpKey]featureExpPool{
+		{name: feature12String, vendor: vendor2String, version: v201812String, expiry: "2018-12-31"}: {
+			expires: 1546214400, licenses: 60,
+		},
+		{name: feature12String, vendor: vendor2String, version: v201812String, expiry: "2018-09-30"}: {
+			expires: 1538265600, licenses: 2,
+		},
+		{name: "feature15", vendor: vendor2String, version: "2018.09", expiry: permanentString}: {
+			expires: math.Inf(posInfinity), licenses: 2,
+		},
+	} {
+		if pool, ok := pools[key]; !ok || *pool != want {
+			t.Fatalf("%v: want %v, got %v", key, want, pool)
+		}
+	}
+
+	if len(pools) != 3 {
+		t.Fatalf("want 3 pools, got %d", len(pools))
+	}
+}
+
+func TestParseExpiryWindow(t *testing.T) {
+	t.Parallel()
+
+	for window, want := range map[string]time.Duration{
+		"30d":  30 * 24 * time.Hour,
+		"1d":   24 * time.Hour,
+		"720h": 720 * time.Hour,
+	} {
+		d, err := parseExpiryWindow(window)
+		if err != nil || d != want {
+			t.Fatalf("%s: want %s, got %s, %v", window, want, d, err)
+		}
+	}
+
+	for _, window := range []string{"", "d", "-1d", "0d", "30days", "0s"} {
+		if _, err := parseExpiryWindow(window); err == nil {
+			t.Fatalf("%s: want error", window)
+		}
+	}
+}
+
+func TestCountExpiring(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2018, 9, 20, 0, 0, 0, 0, time.UTC)
+	pools := mergeFeaturesExp([]*featureExp{
+		// Expired.
+		{name: "feature1", expires: 1536796800, licenses: "4", vendor: vendor2String, version: "1.0"},
+		// In 10 days.
+		{name: feature12String, expires: 1538265600, licenses: "2", vendor: vendor2String, version: v201812String},
+		// In 102 days.
+		{name: feature12String, expires: 1546214400, licenses: "50", vendor: vendor2String, version: v201812String},
+		{name: "feature15", expires: math.Inf(posInfinity), licenses: "2", vendor: vendor2String, version: "2018.09"},
+	})
+	windows := []expiryWindow{
+		{label: "7d", duration: 7 * day},
+		{label: "14d", duration: 14 * day},
+		{label: "365d", duration: 365 * day},
+	}
+
+	expiring, expired := countExpiring(pools, windows, now)
+
+	// The two feature12 pools expiring within 365d count as one feature.
+	want := []expiryCount{{0, 0}, {1, 2}, {1, 52}}
+	for i, w := range windows {
+		if expiring[i] != want[i] {
+			t.Fatalf("%s: want %v, got %v", w.label, want[i], expiring[i])
+		}
+	}
+
+	if expired != (expiryCount{features: 1, licenses: 4}) {
+		t.Fatalf("want 1 feature and 4 licenses expired, got %v", expired)
+	}
+}
+
+func TestCountExpiringPools(t *testing.T) {
+	t.Parallel()
+
+	now := time.Date(2018, 9, 20, 0, 0, 0, 0, time.UTC)
+	pools := mergeFeaturesExp([]*featureExp{
+		// Two pools of feature1 expired, two in 10 and 12 days.
+		{name: "feature1", expires: 1536796800, licenses: "4", vendor: vendor2String, version: "1.0"},
+		{name: "feature1", expires: 1536796800, licenses: "1", vendor: vendor2String, version: "2.0"},
+		{name: "feature1", expires: 1538265600, licenses: "2", vendor: vendor2String, version: "1.0"},
+		{name: "feature1", expires: 1538438400, licenses: "3", vendor: vendor2String, version: "2.0"},
+	})
+	windows := []expiryWindow{{label: "14d", duration: 14 * day}}
+
+	expiring, expired := countExpiring(pools, windows, now)
+
+	if expiring[0] != (expiryCount{features: 1, licenses: 5}) {
+		t.Fatalf("want 1 feature and 5 licenses expiring, got %v", expiring[0])
+	}
+
+	if expired != (expiryCount{features: 1, licenses: 5}) {
+		t.Fatalf("want 1 feature and 5 licenses expired, got %v", expired)
+	}
+}
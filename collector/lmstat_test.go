@@ -15,11 +15,17 @@
 package collector
 
 import (
+	"bytes"
+	"log/slog"
 	"os"
+	"reflect"
 	"strconv"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/mjtrangoni/flexlm_exporter/config"
+	"github.com/mjtrangoni/flexlm_exporter/lmstat"
 	"github.com/prometheus/common/promslog"
 )
 
@@ -32,6 +38,8 @@ const (
 	testParseLmstatLicenseInfo4 = "fixtures/lmstat_app4.txt"
 	testParseLmstatLicenseInfo5 = "fixtures/lmstat_app5.txt"
 	testParseLmstatLicenseInfo6 = "fixtures/lmstat_app6.txt"
+	testParseLmstatLicenseInfo7 = "fixtures/lmstat_app7.txt"
+	testParseLmstatPools        = "fixtures/lmstat_pools.txt"
 	testParseLmstatServerDown   = "fixtures/lmstat_server_down.txt"
 	testParseLmstatServerUp     = "fixtures/lmstat_server_up_win.txt"
 )
@@ -58,12 +66,12 @@ func TestParseLmstatVersion(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err := splitOutput(dataByte)
+	doc, err := lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	lmstatInfo := parseLmstatVersion(dataStr)
+	lmstatInfo := parseLmstatVersion(doc)
 	if lmstatInfo.arch != "x64_lsb" || lmstatInfo.build != "188735" || lmstatInfo.version != "v11.14.0.1" {
 		t.Fatalf("Unexpected values %s, %s, %s != x64_lsb, 188735, v11.14.0.1", lmstatInfo.arch, lmstatInfo.build, lmstatInfo.version)
 	}
@@ -73,12 +81,12 @@ func TestParseLmstatVersion(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err = splitOutput(dataByte)
+	doc, err = lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	lmstatInfo = parseLmstatVersion(dataStr)
+	lmstatInfo = parseLmstatVersion(doc)
 	if lmstatInfo.arch != notFound || lmstatInfo.build != notFound ||
 		lmstatInfo.version != notFound {
 		t.Fatalf("Unexpected values %s, %s, %s != %s", lmstatInfo.arch,
@@ -90,7 +98,7 @@ func TestParseLmstatLicenseInfoServer(t *testing.T) {
 	var (
 		err      error
 		dataByte []byte
-		dataStr  [][]string
+		doc      *lmstat.Document
 	)
 
 	t.Parallel()
@@ -100,12 +108,12 @@ func TestParseLmstatLicenseInfoServer(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err = splitOutput(dataByte)
+	doc, err = lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	servers := parseLmstatLicenseInfoServer(dataStr)
+	servers := parseLmstatLicenseInfoServer(doc)
 	for _, info := range servers {
 		switch info.fqdn {
 		case "host-1.domain.net", "host3.domain.net":
@@ -128,12 +136,12 @@ func TestParseLmstatLicenseInfoServer(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err = splitOutput(dataByte)
+	doc, err = lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	servers = parseLmstatLicenseInfoServer(dataStr)
+	servers = parseLmstatLicenseInfoServer(doc)
 	for _, info := range servers {
 		switch info.fqdn {
 		case "host1":
@@ -161,12 +169,12 @@ func TestParseLmstatLicenseInfoServer(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err = splitOutput(dataByte)
+	doc, err = lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	servers = parseLmstatLicenseInfoServer(dataStr)
+	servers = parseLmstatLicenseInfoServer(doc)
 	for _, info := range servers {
 		if info.fqdn != "bvs15004" || info.version != "v11.12" ||
 			!info.master || !info.status {
@@ -184,12 +192,12 @@ func TestParseLmstatLicenseInfoVendor(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err := splitOutput(dataByte)
+	doc, err := lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	vendors := parseLmstatLicenseInfoVendor(dataStr)
+	vendors := parseLmstatLicenseInfoVendor(doc)
 	for name, info := range vendors {
 		if name == "VENDOR1" {
 			if !info.status || info.version != "v11.6" {
@@ -210,13 +218,13 @@ func TestParseLmstatLicenseInfoFeature(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err := splitOutput(dataByte)
+	doc, err := lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	logger := promslog.New(&promslog.Config{})
-	features, licUsersByFeature, reservGroupByFeature, reservHostByFeature := parseLmstatLicenseInfoFeature(dataStr, logger)
+	features, licUsersByFeature, reservGroupByFeature, reservHostByFeature := parseLmstatLicenseInfoFeature(doc, logger)
 
 	for name, info := range features {
 		switch name {
@@ -397,13 +405,13 @@ func TestParseLmstatLicenseInfoUserSince(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err := splitOutput(dataByte)
+	doc, err := lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	logger := promslog.New(&promslog.Config{})
-	_, licUsersByFeature, _, _ := parseLmstatLicenseInfoFeature(dataStr,
+	_, licUsersByFeature, _, _ := parseLmstatLicenseInfoFeature(doc,
 		logger)
 
 	// the year does not matter in this case, since lmstat omits the year information
@@ -456,13 +464,13 @@ func TestParseLmstatLicenseInfoUserSince(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err = splitOutput(dataByte)
+	doc, err = lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
 	logger = promslog.New(&promslog.Config{})
-	_, licUsersByFeature, _, _ = parseLmstatLicenseInfoFeature(dataStr,
+	_, licUsersByFeature, _, _ = parseLmstatLicenseInfoFeature(doc,
 		logger)
 
 	// the year does not matter in this case, since lmstat omits the year information
@@ -522,12 +530,12 @@ func TestParseLmstatLicenseInfoFeatureType(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err := splitOutput(dataByte)
+	doc, err := lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	features, _, _, _ := parseLmstatLicenseInfoFeature(dataStr, logger)
+	features, _, _, _ := parseLmstatLicenseInfoFeature(doc, logger)
 
 	for name, info := range features {
 		switch name {
@@ -553,12 +561,12 @@ func TestParseLmstatLicenseInfoFeatureType(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err = splitOutput(dataByte)
+	doc, err = lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	features, _, _, _ = parseLmstatLicenseInfoFeature(dataStr, logger)
+	features, _, _, _ = parseLmstatLicenseInfoFeature(doc, logger)
 
 	for name, info := range features {
 		switch name {
@@ -578,12 +586,12 @@ func TestParseLmstatLicenseInfoFeatureType(t *testing.T) {
 		t.Fatal(err)
 	}
 
-	dataStr, err = splitOutput(dataByte)
+	doc, err = lmstat.Parse(bytes.NewReader(dataByte))
 	if err != nil {
 		t.Fatal(err)
 	}
 
-	features, _, _, _ = parseLmstatLicenseInfoFeature(dataStr, logger)
+	features, _, _, _ = parseLmstatLicenseInfoFeature(doc, logger)
 
 	for name, info := range features {
 		switch name {
@@ -642,3 +650,271 @@ func TestParseLmstatLicenseInfoFeatureType(t *testing.T) {
 		}
 	}
 }
+
+func TestParseLmstatLicenseInfoSessions(t *testing.T) {
+	t.Parallel()
+
+	dataByte, err := os.ReadFile(testParseLmstatLicenseInfo1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := lmstat.Parse(bytes.NewReader(dataByte))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := promslog.New(&promslog.Config{})
+	sessionsByFeature := parseLmstatLicenseInfoSessions(doc, logger)
+
+	if len(sessionsByFeature["feature8"]) != 1 {
+		t.Fatalf("Unexpected number of sessions for feature8: %d != 1", len(sessionsByFeature["feature8"]))
+	}
+
+	session := sessionsByFeature["feature8"][0]
+	if session.user != "user17" || session.host != "SERVER000020" || session.handle != "18764" {
+		t.Fatalf("Unexpected session for feature8: %s, %s, %s", session.user, session.host, session.handle)
+	}
+
+	if session = sessionsByFeature["feature1"][0]; session.user != "USER9" || session.licenses != 5 {
+		t.Fatalf("Unexpected session for feature1: %s, %v licenses", session.user, session.licenses)
+	}
+
+	// Queued requests are not counted as sessions.
+	if len(sessionsByFeature["feature5"]) != 2 {
+		t.Fatalf("Unexpected number of sessions for feature5: %d != 2", len(sessionsByFeature["feature5"]))
+	}
+
+	if len(sessionsByFeature["feature100"]) != 4 {
+		t.Fatalf("Unexpected number of sessions for feature100: %d != 4", len(sessionsByFeature["feature100"]))
+	}
+}
+
+func TestParseLmstatLicenseInfoPackages(t *testing.T) {
+	t.Parallel()
+
+	dataByte, err := os.ReadFile(testParseLmstatLicenseInfo1)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := lmstat.Parse(bytes.NewReader(dataByte))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	packages := parseLmstatLicenseInfoPackages(doc)
+	if len(packages) != 1 || !packages["feature0"]["feature1"] {
+		t.Fatalf("Unexpected packages: %v", packages)
+	}
+
+	// Pools with the same name as the feature are not packages.
+	dataByte, err = os.ReadFile(testParseLmstatLicenseInfo6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err = lmstat.Parse(bytes.NewReader(dataByte))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if packages = parseLmstatLicenseInfoPackages(doc); len(packages) != 0 {
+		t.Fatalf("Unexpected packages: %v", packages)
+	}
+}
+
+func TestParseLmstatLicenseInfoPools(t *testing.T) {
+	t.Parallel()
+
+	dataByte, err := os.ReadFile(testParseLmstatLicenseInfo6)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := lmstat.Parse(bytes.NewReader(dataByte))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := promslog.New(&promslog.Config{})
+	pools := parseLmstatLicenseInfoPools(doc, logger)
+
+	var sum float64
+	for _, used := range pools["MATLAB"] {
+		sum += used
+	}
+
+	// MATLAB has 60 licenses in use, including a reservation.
+	if len(pools["MATLAB"]) != 3 || sum != 59 {
+		t.Fatalf("Unexpected pools for MATLAB: %v", pools["MATLAB"])
+	}
+
+	if used, ok := pools["MATLAB"][featurePool{name: "MATLAB", version: "12", expiry: permanentString}]; !ok || used != 0 {
+		t.Fatalf("Unexpected usage of the permanent MATLAB pool: %v", pools["MATLAB"])
+	}
+
+	if used := pools["MATLAB"][featurePool{name: "MATLAB", version: "53", expiry: "2099-01-01"}]; used != 49 {
+		t.Fatalf("Unexpected usage of the MATLAB v53 pool: %v != 49", used)
+	}
+}
+
+func TestParseLmstatLicenseInfoPoolsSameVersion(t *testing.T) {
+	t.Parallel()
+
+	dataByte, err := os.ReadFile(testParseLmstatPools)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := lmstat.Parse(bytes.NewReader(dataByte))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := promslog.New(&promslog.Config{})
+	pools := parseLmstatLicenseInfoPools(doc, logger)
+
+	// The two feature1 pools only differ by their vendor_string, and are
+	// summed, the suite1 one is kept apart.
+	want := map[featurePool]float64{
+		{name: "feature1", version: "1.0", expiry: "2030-12-31"}: 3,
+		{name: "suite1", version: "1.0", expiry: "2030-12-31"}:   1,
+	}
+
+	if len(pools["feature1"]) != len(want) {
+		t.Fatalf("Unexpected pools for feature1: %v", pools["feature1"])
+	}
+
+	for pool, used := range want {
+		if got := pools["feature1"][pool]; got != used {
+			t.Fatalf("Unexpected usage of the %v pool: %v != %v", pool, got, used)
+		}
+	}
+}
+
+func TestParseLmstatLicenseInfoReservations(t *testing.T) {
+	t.Parallel()
+
+	dataByte, err := os.ReadFile(testParseLmstatLicenseInfo7)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc, err := lmstat.Parse(bytes.NewReader(dataByte))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	logger := promslog.New(&promslog.Config{})
+
+	expected := map[featureReservation]float64{
+		{targetType: "host", target: "node-01.cluster"}: 2,
+		{targetType: "user", target: "jane.doe"}:        1,
+		{targetType: "display", target: "/dev/pts/3"}:   1,
+		{targetType: "internet", target: "192.168.1.*"}: 3,
+		{targetType: "project", target: "proj-x"}:       1,
+		{targetType: "group", target: "eng-team.a"}:     2,
+		{targetType: "host_group", target: "gpu-nodes"}: 2,
+	}
+
+	reservations := parseLmstatLicenseInfoReservations(doc)
+	if !reflect.DeepEqual(reservations["feature1"], expected) {
+		t.Fatalf("Unexpected reservations: %v", reservations["feature1"])
+	}
+
+	// Host names and groups with dots and dashes are not truncated, and all
+	// the reservations are counted as in use.
+	features, _, reservGroupByFeature, reservHostByFeature := parseLmstatLicenseInfoFeature(doc, logger)
+	if reservHostByFeature["feature1"]["node-01.cluster"] != 2 {
+		t.Fatalf("Unexpected host reservations: %v", reservHostByFeature["feature1"])
+	}
+
+	if reservGroupByFeature["feature1"]["eng-team.a"] != 2 || reservGroupByFeature["feature1"]["gpu-nodes"] != 2 {
+		t.Fatalf("Unexpected group reservations: %v", reservGroupByFeature["feature1"])
+	}
+
+	if used := features["feature1"].usedByType[licenseTypeFloating]; used != 13 {
+		t.Fatalf("Unexpected used licenses for feature1: %v != 13", used)
+	}
+}
+
+func TestParseLmstatOutputUnparsedLines(t *testing.T) {
+	t.Parallel()
+
+	dataByte, err := os.ReadFile(testParseLmstatLicenseInfo4)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var logs bytes.Buffer
+
+	logger := slog.New(slog.NewJSONHandler(&logs, &slog.HandlerOptions{Level: slog.LevelDebug})).With("collector", "lmstat")
+
+	doc, err := parseLmstatOutput(dataByte, "app4", logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The sessions with a non-numeric port are not recognized.
+	if unparsed := doc.Unparsed(lmstat.SectionFeatures); unparsed != 5 {
+		t.Fatalf("Unexpected number of unparsed lines: %d != 5", unparsed)
+	}
+
+	// The collector attribute comes from the logger only.
+	for line := range strings.SplitSeq(strings.TrimSpace(logs.String()), "\n") {
+		if strings.Count(line, `"collector":`) != 1 {
+			t.Fatalf("Unexpected collector attributes: %s", line)
+		}
+	}
+}
+
+func TestLmstatQueries(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		licenses config.License
+		want     [][]string
+	}{
+		{config.License{}, [][]string{{"-a"}}},
+		{config.License{FeaturesToExclude: "feature1"}, [][]string{{"-a"}}},
+		{config.License{FeaturesToInclude: "feature1,feature2,feature1"}, [][]string{{"-f", "feature1"}, {"-f", "feature2"}}},
+		{config.License{FeaturesToInclude: "f1,f2,f3"}, [][]string{{"-a"}}},
+		{config.License{FeaturesToInclude: "f1,f2,f3", Vendor: "VENDOR1"}, [][]string{{"-S", "VENDOR1"}}},
+		{config.License{Vendor: "VENDOR1"}, [][]string{{"-S", "VENDOR1"}}},
+	} {
+		if got := lmstatQueries(&tc.licenses, 2); !reflect.DeepEqual(got, tc.want) {
+			t.Fatalf("%+v: want %v, got %v", tc.licenses, tc.want, got)
+		}
+	}
+}
+
+func TestLmstatLicenseOutputFeatures(t *testing.T) {
+	replayDir, maxFeatureQueries := *lmutilReplayDir, *lmstatMaxFeatureQueries
+	*lmutilReplayDir, *lmstatMaxFeatureQueries = "fixtures", 5
+
+	defer func() { *lmutilReplayDir, *lmstatMaxFeatureQueries = replayDir, maxFeatureQueries }()
+
+	c := &lmstatCollector{logger: promslog.New(&promslog.Config{})}
+
+	doc, err := c.lmstatLicenseOutput(&config.License{
+		Name:              "app8",
+		LicenseServer:     "27002@host2.domain.net",
+		FeaturesToInclude: "feature3,feature5",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Servers) != 3 || len(doc.Vendors) != 1 {
+		t.Fatalf("want the servers and vendors status, got %d servers, %d vendors", len(doc.Servers), len(doc.Vendors))
+	}
+
+	if len(doc.Features) != 2 || doc.Feature("feature3") == nil || len(doc.Feature("feature5").Sessions) != 2 {
+		t.Fatalf("want feature3 and feature5 with 2 sessions, got %+v", doc.Features)
+	}
+
+	if len(doc.Diagnostics) != 0 {
+		t.Fatalf("unexpected unparsed lines %v", doc.Diagnostics)
+	}
+}
@@ -0,0 +1,235 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"io"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mjtrangoni/flexlm_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	optionsGroup      = "GROUP"
+	optionsHostGroup  = "HOST_GROUP"
+	optionsReserve    = "RESERVE"
+	optionsMax        = "MAX"
+	optionsInclude    = "INCLUDE"
+	optionsExclude    = "EXCLUDE"
+	optionsIncludeAll = "INCLUDEALL"
+	optionsExcludeAll = "EXCLUDEALL"
+
+	// Minimum number of fields of the options file lines.
+	groupFields   = 2
+	limitFields   = 5
+	ruleFields    = 4
+	ruleAllFields = 3
+)
+
+// readOptionsFile reads and parses a vendor daemon options file.
+func readOptionsFile(path string, logger *slog.Logger) (*optionsFile, error) {
+	file, err := os.Open(filepath.Clean(path))
+	if err != nil {
+		return nil, fmt.Errorf("couldn't open options file %s: %w", path, err)
+	}
+	defer file.Close()
+
+	return parseOptionsFile(file, logger)
+}
+
+// parseOptionsFile parses the GROUP, HOST_GROUP, RESERVE, MAX, INCLUDE,
+// EXCLUDE, INCLUDEALL and EXCLUDEALL lines of an options file. The other
+// keywords are ignored.
+func parseOptionsFile(r io.Reader, logger *slog.Logger) (*optionsFile, error) {
+	of := &optionsFile{
+		groups:     make(map[string][]string),
+		hostGroups: make(map[string][]string),
+	}
+
+	err := scanContinuedLines(r, func(line string) {
+		of.parseLine(line, logger)
+	})
+	if err != nil {
+		return of, fmt.Errorf("could not parse options file: %w", err)
+	}
+
+	return of, nil
+}
+
+func (of *optionsFile) parseLine(line string, logger *slog.Logger) {
+	fields := splitQuoted(line)
+	if len(fields) == 0 {
+		return
+	}
+
+	keyword := strings.ToUpper(fields[0])
+
+	switch keyword {
+	case optionsGroup, optionsHostGroup:
+		if len(fields) < groupFields {
+			logger.Debug("invalid options file "+keyword+" line", "line", line)
+			return
+		}
+
+		// Several lines with the same group name are merged.
+		if keyword == optionsGroup {
+			of.groups[fields[1]] = append(of.groups[fields[1]], fields[2:]...)
+		} else {
+			of.hostGroups[fields[1]] = append(of.hostGroups[fields[1]], fields[2:]...)
+		}
+	case optionsReserve, optionsMax:
+		if len(fields) < limitFields {
+			logger.Debug("invalid options file "+keyword+" line", "line", line)
+			return
+		}
+
+		count, err := strconv.Atoi(fields[1])
+		if err != nil {
+			logger.Error("err", "could not convert", fields[1], "to integer:", err)
+			return
+		}
+
+		limit := &optionsLimit{
+			feature:    optionsFeatureName(fields[2]),
+			targetType: strings.ToLower(fields[3]),
+			target:     fields[4],
+			count:      float64(count),
+		}
+
+		if keyword == optionsReserve {
+			of.reservations = append(of.reservations, limit)
+		} else {
+			of.maxes = append(of.maxes, limit)
+		}
+	case optionsInclude, optionsExclude:
+		if len(fields) < ruleFields {
+			logger.Debug("invalid options file "+keyword+" line", "line", line)
+			return
+		}
+
+		of.rules = append(of.rules, &optionsRule{
+			rule:       strings.ToLower(keyword),
+			feature:    optionsFeatureName(fields[1]),
+			targetType: strings.ToLower(fields[2]),
+			target:     fields[3],
+		})
+	case optionsIncludeAll, optionsExcludeAll:
+		if len(fields) < ruleAllFields {
+			logger.Debug("invalid options file "+keyword+" line", "line", line)
+			return
+		}
+
+		of.rules = append(of.rules, &optionsRule{
+			rule:       strings.ToLower(keyword),
+			targetType: strings.ToLower(fields[1]),
+			target:     fields[2],
+		})
+	}
+}
+
+// optionsFeatureName returns the feature name of a feature[:keyword=value]
+// specification.
+func optionsFeatureName(spec string) string {
+	return strings.Split(spec, ":")[0]
+}
+
+// reservedByFeature returns the total number of licenses reserved by feature.
+func (of *optionsFile) reservedByFeature() map[string]float64 {
+	reserved := make(map[string]float64)
+	for _, r := range of.reservations {
+		reserved[r.feature] += r.count
+	}
+
+	return reserved
+}
+
+// collectOptionsFile sends the reservations, MAX limits, INCLUDE and EXCLUDE
+// rules and groups of the options file of a license, and flags the features
+// with more reserved than issued licenses.
+func (c *lmstatCollector) collectOptionsFile(licenses *config.License, of *optionsFile, features map[string]*feature,
+	filtered func(name string) bool, ch chan<- prometheus.Metric) {
+	// Several lines for the same feature and target are summed.
+	limits := func(desc *prometheus.Desc, lines []*optionsLimit) {
+		sums := make(map[optionsLimit]float64)
+
+		for _, l := range lines {
+			if !filtered(l.feature) {
+				sums[optionsLimit{feature: l.feature, targetType: l.targetType, target: l.target}] += l.count
+			}
+		}
+
+		for l, count := range sums {
+			ch <- prometheus.MustNewConstMetric(desc, prometheus.GaugeValue, count,
+				licenses.Name, l.feature, l.targetType, l.target)
+		}
+	}
+
+	limits(c.lmstatOptionsReserved, of.reservations)
+	limits(c.lmstatOptionsMax, of.maxes)
+
+	rules := make(map[optionsRule]bool)
+	for _, r := range of.rules {
+		if r.feature == "" || !filtered(r.feature) {
+			rules[*r] = true
+		}
+	}
+
+	for r := range rules {
+		ch <- prometheus.MustNewConstMetric(c.lmstatOptionsRuleInfo, prometheus.GaugeValue, 1.0,
+			licenses.Name, r.feature, r.rule, r.targetType, r.target)
+	}
+
+	groupMembers := func(groupType string, groups map[string][]string) {
+		for group, members := range groups {
+			seen := make(map[string]bool, len(members))
+
+			for _, member := range members {
+				if seen[member] {
+					continue
+				}
+
+				seen[member] = true
+				ch <- prometheus.MustNewConstMetric(c.lmstatOptionsGroupInfo, prometheus.GaugeValue, 1.0,
+					licenses.Name, group, groupType, member)
+			}
+		}
+	}
+
+	groupMembers(strings.ToLower(optionsGroup), of.groups)
+	groupMembers(strings.ToLower(optionsHostGroup), of.hostGroups)
+
+	for name, reserved := range of.reservedByFeature() {
+		info, ok := features[name]
+		if !ok || filtered(name) {
+			continue
+		}
+
+		exceeded := 0.0
+		if reserved > info.issued {
+			exceeded = 1.0
+
+			c.logger.Warn("options file reserves more licenses than issued", "app", licenses.Name,
+				"feature", name, "reserved", reserved, "issued", info.issued)
+		}
+
+		ch <- prometheus.MustNewConstMetric(c.lmstatOptionsReservationExceeded, prometheus.GaugeValue, exceeded,
+			licenses.Name, name)
+	}
+}
@@ -0,0 +1,77 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+)
+
+const (
+	testOptionsFile1 = "fixtures/options_app1.opt"
+)
+
+func TestReadOptionsFile(t *testing.T) {
+	t.Parallel()
+
+	logger := promslog.New(&promslog.Config{})
+
+	of, err := readOptionsFile(testOptionsFile1, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if groups := of.groups["engineers"]; !reflect.DeepEqual(groups, []string{"user1", "user2", "user3", "user4"}) {
+		t.Fatalf("Unexpected GROUP members: %v", groups)
+	}
+
+	if hosts := of.hostGroups["workstations"]; !reflect.DeepEqual(hosts, []string{"host1", "host2.domain.net"}) {
+		t.Fatalf("Unexpected HOST_GROUP members: %v", hosts)
+	}
+
+	if len(of.reservations) != 3 {
+		t.Fatalf("Unexpected number of RESERVE lines: %d != 3", len(of.reservations))
+	}
+
+	if r := of.reservations[1]; r.feature != "feature1" || r.targetType != "user" || r.target != "user5" || r.count != 1 {
+		t.Fatalf("Unexpected RESERVE line with feature keyword: %+v", r)
+	}
+
+	reserved := of.reservedByFeature()
+	if reserved["feature1"] != 3 || reserved["feature2"] != 12 {
+		t.Fatalf("Unexpected reserved licenses: %v", reserved)
+	}
+
+	if len(of.maxes) != 2 || of.maxes[1].feature != "feature3" || of.maxes[1].count != 1 {
+		t.Fatalf("Unexpected MAX lines: %+v", of.maxes)
+	}
+
+	expectedRules := []optionsRule{
+		{rule: "include", feature: "feature1", targetType: "group", target: "engineers"},
+		{rule: "exclude", feature: "feature2", targetType: "user", target: "user7"},
+		{rule: "excludeall", targetType: "host", target: "host3"},
+	}
+
+	if len(of.rules) != len(expectedRules) {
+		t.Fatalf("Unexpected number of rules: %d != %d", len(of.rules), len(expectedRules))
+	}
+
+	for i, r := range of.rules {
+		if *r != expectedRules[i] {
+			t.Fatalf("Unexpected rule: %+v != %+v", *r, expectedRules[i])
+		}
+	}
+}
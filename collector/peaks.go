@@ -0,0 +1,126 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"sync"
+	"time"
+)
+
+type peakSample struct {
+	at   time.Time
+	used float64
+}
+
+// peakTracker keeps the feature usage samples seen by the background poller
+// and the scrapes, so the maximum and minimum usage between two scrapes, or
+// over a fixed window, can be exported.
+type peakTracker struct {
+	mtx     sync.Mutex
+	window  time.Duration
+	samples map[string]map[string][]peakSample
+}
+
+func newPeakTracker(window time.Duration) *peakTracker {
+	return &peakTracker{
+		window:  window,
+		samples: make(map[string]map[string][]peakSample),
+	}
+}
+
+// observe records the usage of a feature.
+func (t *peakTracker) observe(app, name string, used float64, now time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if t.samples[app] == nil {
+		t.samples[app] = make(map[string][]peakSample)
+	}
+
+	samples := append(t.expire(t.samples[app][name], now), peakSample{at: now, used: used})
+	if t.window <= 0 {
+		samples = minMaxSamples(samples)
+	}
+
+	t.samples[app][name] = samples
+}
+
+// minMaxSamples keeps only the minimum and maximum samples. Without a window,
+// nothing else is needed until the next scrape, so the samples of a poller
+// running without scrapes don't grow.
+func minMaxSamples(samples []peakSample) []peakSample {
+	if len(samples) <= 2 {
+		return samples
+	}
+
+	minSample, maxSample := samples[0], samples[0]
+	for _, s := range samples[1:] {
+		if s.used < minSample.used {
+			minSample = s
+		}
+
+		if s.used > maxSample.used {
+			maxSample = s
+		}
+	}
+
+	return []peakSample{minSample, maxSample}
+}
+
+// minMax returns the minimum and maximum usage of a feature, and whether any
+// sample was found.
+func (t *peakTracker) minMax(app, name string, now time.Time) (minUsed, maxUsed float64, ok bool) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	samples := t.expire(t.samples[app][name], now)
+	for i, s := range samples {
+		if i == 0 || s.used < minUsed {
+			minUsed = s.used
+		}
+
+		if i == 0 || s.used > maxUsed {
+			maxUsed = s.used
+		}
+	}
+
+	return minUsed, maxUsed, len(samples) > 0
+}
+
+// reset drops the samples of an app when no window is configured, so the
+// next scrape starts a new period.
+func (t *peakTracker) reset(app string) {
+	if t.window > 0 {
+		return
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	delete(t.samples, app)
+}
+
+// expire removes the samples older than the window.
+func (t *peakTracker) expire(samples []peakSample, now time.Time) []peakSample {
+	if t.window <= 0 {
+		return samples
+	}
+
+	i := 0
+	for i < len(samples) && now.Sub(samples[i].at) > t.window {
+		i++
+	}
+
+	return samples[i:]
+}
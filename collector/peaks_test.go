@@ -0,0 +1,65 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"testing"
+	"time"
+)
+
+func TestPeakTracker(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	tracker := newPeakTracker(0)
+	tracker.observe("app1", "feature1", 5, now)
+	tracker.observe("app1", "feature1", 12, now.Add(10*time.Second))
+	tracker.observe("app1", "feature1", 3, now.Add(20*time.Second))
+
+	minUsed, maxUsed, ok := tracker.minMax("app1", "feature1", now.Add(30*time.Second))
+	if !ok || minUsed != 3 || maxUsed != 12 {
+		t.Fatalf("Unexpected values for feature1: %v!=3 %v!=12", minUsed, maxUsed)
+	}
+
+	// Without a window, only the minimum and maximum samples are kept.
+	for i := range 100 {
+		tracker.observe("app1", "feature1", float64(4+i%8), now.Add(time.Duration(i)*time.Second))
+	}
+
+	if samples := len(tracker.samples["app1"]["feature1"]); samples != 2 {
+		t.Fatalf("Unexpected samples for feature1: %d != 2", samples)
+	}
+
+	minUsed, maxUsed, ok = tracker.minMax("app1", "feature1", now.Add(time.Hour))
+	if !ok || minUsed != 3 || maxUsed != 12 {
+		t.Fatalf("Unexpected values for feature1: %v!=3 %v!=12", minUsed, maxUsed)
+	}
+
+	// Without a window, the samples are dropped on each scrape.
+	tracker.reset("app1")
+
+	if _, _, ok = tracker.minMax("app1", "feature1", now.Add(30*time.Second)); ok {
+		t.Fatalf("Unexpected samples for feature1 after reset")
+	}
+
+	tracker = newPeakTracker(time.Minute)
+	tracker.observe("app1", "feature1", 12, now)
+	tracker.observe("app1", "feature1", 5, now.Add(time.Minute))
+	tracker.reset("app1")
+
+	minUsed, maxUsed, ok = tracker.minMax("app1", "feature1", now.Add(90*time.Second))
+	if !ok || minUsed != 5 || maxUsed != 5 {
+		t.Fatalf("Unexpected values for feature1: %v!=5 %v!=5", minUsed, maxUsed)
+	}
+}
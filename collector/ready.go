@@ -0,0 +1,82 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"os/exec"
+	"time"
+
+	"github.com/mjtrangoni/flexlm_exporter/lmstat"
+)
+
+// readyTimeout is the timeout of lmstat -v in the readiness probes.
+var readyTimeout = 5 * time.Second
+
+// Ready returns an error unless the license configuration is loaded, and the
+// lmutil binary is executable and its lmstat -v output can be parsed within
+// readyTimeout. It never queries a license. With --lmutil.replay-dir, the
+// directory has to exist instead.
+func Ready(logger *slog.Logger) error {
+	if len(LicenseConfig.Licenses) == 0 {
+		return errors.New("no license configured")
+	}
+
+	if *lmutilReplayDir != "" {
+		if _, err := os.Stat(*lmutilReplayDir); err != nil {
+			return fmt.Errorf("couldn't find replay directory: %w", err)
+		}
+
+		return nil
+	}
+
+	info, err := os.Stat(*lmutilPath)
+	if err != nil {
+		return fmt.Errorf("couldn't find lmutil: %w", err)
+	}
+
+	if info.IsDir() || info.Mode().Perm()&0o111 == 0 {
+		return fmt.Errorf("%s is not executable", *lmutilPath)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), readyTimeout)
+	defer cancel()
+
+	// lmutil is called directly, so that the probes don't wait for the scrapes
+	// in --lmutil.max-concurrency, and are neither recorded nor instrumented.
+	cmd := exec.CommandContext(ctx, *lmutilPath, "lmstat", "-v")
+	cmd.Env = append(os.Environ(), "LANG=C")
+
+	outBytes, err := cmd.Output()
+	if err != nil {
+		logger.Debug("lmstat -v failed", "err", err)
+		return newLmutilError([]string{"lmstat", "-v"}, err)
+	}
+
+	doc, err := lmstat.Parse(bytes.NewReader(outBytes))
+	if err != nil {
+		return err
+	}
+
+	if parseLmstatVersion(doc).version == notFound {
+		return errors.New("couldn't parse lmstat -v output")
+	}
+
+	return nil
+}
@@ -0,0 +1,148 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/mjtrangoni/flexlm_exporter/config"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestReady(t *testing.T) {
+	logger := promslog.New(&promslog.Config{})
+
+	licenseConfig, path, replayDir := LicenseConfig, *lmutilPath, *lmutilReplayDir
+
+	defer func() { LicenseConfig, *lmutilPath, *lmutilReplayDir = licenseConfig, path, replayDir }()
+
+	dir := t.TempDir()
+	lmutil := filepath.Join(dir, "lmutil")
+	noVersion := filepath.Join(dir, "lmutil_no_version")
+
+	if err := os.WriteFile(lmutil, []byte("#!/bin/sh\necho 'lmstat v11.14.1.3 build 212549 x64_lsb'\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(noVersion, []byte("#!/bin/sh\necho 'lmutil - Copyright (c) 1989-2017'\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	*lmutilReplayDir = ""
+	*lmutilPath = lmutil
+	LicenseConfig = config.Configuration{}
+
+	if err := Ready(logger); err == nil {
+		t.Fatal("want error without licenses")
+	}
+
+	LicenseConfig = config.Configuration{Licenses: []config.License{{Name: "app1", LicenseServer: "27000@host1"}}}
+
+	if err := Ready(logger); err == nil {
+		t.Fatal("want error for a not executable lmutil")
+	}
+
+	if err := os.Chmod(lmutil, 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := Ready(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	*lmutilPath = noVersion
+	if err := Ready(logger); err == nil {
+		t.Fatal("want error without lmstat version")
+	}
+
+	*lmutilPath = filepath.Join(dir, "missing")
+	if err := Ready(logger); err == nil {
+		t.Fatal("want error for a missing lmutil")
+	}
+
+	*lmutilReplayDir = "fixtures"
+	if err := Ready(logger); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadyBypassesLmutilLimits(t *testing.T) {
+	logger := promslog.New(&promslog.Config{})
+
+	licenseConfig, path, replayDir, recordDir := LicenseConfig, *lmutilPath, *lmutilReplayDir, *lmutilRecordDir
+	maxConcurrency, timeout := *lmutilMaxConcurrency, readyTimeout
+
+	defer func() {
+		LicenseConfig, *lmutilPath, *lmutilReplayDir, *lmutilRecordDir = licenseConfig, path, replayDir, recordDir
+		*lmutilMaxConcurrency, readyTimeout = maxConcurrency, timeout
+	}()
+
+	dir := t.TempDir()
+	lmutil := filepath.Join(dir, "lmutil")
+	slow := filepath.Join(dir, "lmutil_slow")
+
+	if err := os.WriteFile(lmutil, []byte("#!/bin/sh\necho 'lmstat v11.14.1.3 build 212549 x64_lsb'\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(slow, []byte("#!/bin/sh\nexec sleep 10\n"), 0o700); err != nil {
+		t.Fatal(err)
+	}
+
+	LicenseConfig = config.Configuration{Licenses: []config.License{{Name: "app1", LicenseServer: "27000@host1"}}}
+	*lmutilReplayDir = ""
+	*lmutilRecordDir = t.TempDir()
+	*lmutilPath = lmutil
+
+	// A scrape holding the only slot must not block the probe.
+	*lmutilMaxConcurrency = 1
+
+	lmutilSlots.acquire("app1", 1)
+	defer lmutilSlots.release()
+
+	executions := testutil.CollectAndCount(lmutilExecutions)
+
+	if err := Ready(logger); err != nil {
+		t.Fatal(err)
+	}
+
+	if got := testutil.CollectAndCount(lmutilExecutions); got != executions {
+		t.Errorf("got %d lmutil execution series, want %d", got, executions)
+	}
+
+	entries, err := os.ReadDir(*lmutilRecordDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(entries) != 0 {
+		t.Errorf("got %d recorded files, want none", len(entries))
+	}
+
+	*lmutilPath = slow
+	readyTimeout = 100 * time.Millisecond
+
+	begin := time.Now()
+	if err := Ready(logger); err == nil {
+		t.Fatal("want error for a lmutil exceeding the timeout")
+	}
+
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Errorf("Ready took %s with a timeout of %s", elapsed, readyTimeout)
+	}
+}
@@ -0,0 +1,149 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	kingpin "github.com/alecthomas/kingpin/v2"
+	"github.com/mjtrangoni/flexlm_exporter/lmstat"
+)
+
+const recordTimeFormat = "20060102T150405.000Z"
+
+var (
+	lmutilRecordDir = kingpin.Flag("lmutil.record-dir",
+		"Directory where the raw output of each lmutil call is saved with a timestamp, "+
+			"e.g. lmstat_app1_20261019T132501.000Z.txt.").Default("").String()
+	lmutilRecordKeep = kingpin.Flag("lmutil.record-keep",
+		"Number of recorded files kept for each lmutil call, the oldest are removed. Use 0 to keep them all.").
+		Default("100").Int()
+	lmutilRecordRedact = kingpin.Flag("lmutil.record-redact",
+		"Replace the usernames and hostnames of the recorded lmutil output consistently.").Default("false").Bool()
+
+	// recordRedactor keeps the same aliases for all the recorded files.
+	recordRedactor = newRedactor()
+)
+
+// redactor replaces usernames and hostnames with user<n> and host<n>.
+type redactor struct {
+	mu          sync.Mutex
+	userAliases map[string]string
+	hostAliases map[string]string
+}
+
+func newRedactor() *redactor {
+	return &redactor{userAliases: make(map[string]string), hostAliases: make(map[string]string)}
+}
+
+func (r *redactor) user(name string) string {
+	alias, ok := r.userAliases[name]
+	if !ok {
+		alias = fmt.Sprintf("user%d", len(r.userAliases)+1)
+		r.userAliases[name] = alias
+	}
+
+	return alias
+}
+
+// host returns the alias of a hostname, the same for its short and fully
+// qualified names, as the parser matches the servers by short name. Paths,
+// e.g. /dev/tty displays, and IP addresses are not shortened.
+func (r *redactor) host(name string) string {
+	if strings.HasPrefix(name, "/") {
+		return name
+	}
+
+	key := strings.ToLower(name)
+	if net.ParseIP(name) == nil {
+		key, _, _ = strings.Cut(key, ".")
+	}
+
+	alias, ok := r.hostAliases[key]
+	if !ok {
+		alias = fmt.Sprintf("host%d", len(r.hostAliases)+1)
+		r.hostAliases[key] = alias
+	}
+
+	return alias
+}
+
+// redact replaces the usernames and hostnames of the lmstat output, only where
+// lmstat.Redact finds them.
+func (r *redactor) redact(out []byte) []byte {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	return lmstat.Redact(out, func(field lmstat.Field, value string) string {
+		if field == lmstat.FieldUser {
+			return r.user(value)
+		}
+
+		return r.host(value)
+	})
+}
+
+// recordOutput saves the raw output of an lmutil call in --lmutil.record-dir,
+// named like the files replayed with --lmutil.replay-dir, followed by the
+// time of the call. Only the --lmutil.record-keep newest files of the call are
+// kept.
+func recordOutput(logger *slog.Logger, app string, args []string, out []byte, now time.Time) {
+	if *lmutilRecordRedact {
+		out = recordRedactor.redact(out)
+	}
+
+	prefix := strings.TrimSuffix(replayFileName(app, args), ".txt") + "_"
+	path := filepath.Join(*lmutilRecordDir, prefix+now.UTC().Format(recordTimeFormat)+".txt")
+
+	err := os.WriteFile(filepath.Clean(path), out, 0o600)
+	if err != nil {
+		logger.Warn("couldn't record lmutil output", "app", app, "path", path, "err", err)
+		return
+	}
+
+	pruneRecords(logger, prefix)
+}
+
+// pruneRecords removes the oldest recorded files starting with prefix, beyond
+// --lmutil.record-keep. The timestamps in the names sort in time order.
+func pruneRecords(logger *slog.Logger, prefix string) {
+	if *lmutilRecordKeep <= 0 {
+		return
+	}
+
+	// The timestamp pattern keeps the files of an app named like
+	// <app>_<suffix> apart.
+	pattern := filepath.Join(*lmutilRecordDir, prefix+"????????T??????.???Z.txt")
+
+	paths, err := filepath.Glob(pattern)
+	if err != nil || len(paths) <= *lmutilRecordKeep {
+		return
+	}
+
+	sort.Strings(paths)
+
+	for _, path := range paths[:len(paths)-*lmutilRecordKeep] {
+		if err := os.Remove(path); err != nil {
+			logger.Warn("couldn't remove recorded lmutil output", "path", path, "err", err)
+		}
+	}
+}
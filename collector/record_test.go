@@ -0,0 +1,187 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"slices"
+	"testing"
+	"time"
+
+	"github.com/mjtrangoni/flexlm_exporter/lmstat"
+	"github.com/prometheus/common/promslog"
+)
+
+func TestRedactor(t *testing.T) {
+	dataByte, err := os.ReadFile("fixtures/lmstat_app1.txt")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := newRedactor()
+	redacted := r.redact(dataByte)
+
+	if bytes.Contains(redacted, []byte("domain.net")) || bytes.Contains(redacted, []byte("HOSTPC12")) {
+		t.Fatal("hostnames not redacted")
+	}
+
+	before, err := lmstat.Parse(bytes.NewReader(dataByte))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := lmstat.Parse(bytes.NewReader(redacted))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(after.Diagnostics) != len(before.Diagnostics) || len(after.Features) != len(before.Features) {
+		t.Fatalf("redacted output doesn't parse the same")
+	}
+
+	for i, f := range before.Features {
+		if len(after.Features[i].Sessions) != len(f.Sessions) || after.Features[i].Used != f.Used {
+			t.Fatalf("%s: redacted sessions don't match", f.Name)
+		}
+
+		for j, s := range f.Sessions {
+			got := after.Features[i].Sessions[j]
+			if got.User != r.userAliases[s.User] || got.Host != r.host(s.Host) {
+				t.Fatalf("%s: want %s@%s redacted, got %s@%s", f.Name, s.User, s.Host, got.User, got.Host)
+			}
+		}
+	}
+
+	// The same names get the same aliases.
+	if !bytes.Equal(r.redact(dataByte), redacted) {
+		t.Fatal("redaction is not consistent")
+	}
+
+	if r.userAliases["user2"] == r.userAliases["user3"] || r.host("host2.domain.net") != r.host("host2") {
+		t.Fatalf("unexpected aliases %v %v", r.userAliases, r.hostAliases)
+	}
+
+	if len(before.Servers) != len(after.Servers) || after.Servers[0].Name != r.host("host-1") || !after.Servers[1].Master {
+		t.Fatalf("redacted servers don't match")
+	}
+}
+
+func TestRedactorCollisions(t *testing.T) {
+	t.Parallel()
+
+	// The user feature1 and the host users are named like a feature and a
+	// keyword, which are kept.
+	out := []byte("License server status: 27000@users\n" +
+		"users: license server UP (MASTER) v11.7\n" +
+		"Users of feature1:  (Total of 2 licenses issued;  Total of 2 licenses in use)\n" +
+		"  \"feature1\" v1.0, vendor: VENDOR1\n" +
+		"  floating license\n" +
+		"    feature1 users /dev/tty (v1.0) (users/27000 101), start Fri 10/20 14:12, 2 licenses\n" +
+		"    1 RESERVATION for USER feature1 (users/27000)\n")
+
+	r := newRedactor()
+	redacted := r.redact(out)
+
+	doc, err := lmstat.Parse(bytes.NewReader(redacted))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Diagnostics) != 0 || len(doc.Features) != 1 || doc.Features[0].Name != "feature1" ||
+		doc.Features[0].Pools[0].Name != "feature1" {
+		t.Fatalf("redacted output doesn't parse the same: %s", redacted)
+	}
+
+	f := doc.Features[0]
+	if len(f.Sessions) != 1 || f.Sessions[0].User != "user1" || f.Sessions[0].Host != "host1" ||
+		f.Sessions[0].Server != "host1" || f.Sessions[0].Display != "/dev/tty" {
+		t.Fatalf("unexpected redacted sessions: %s", redacted)
+	}
+
+	if len(f.Reservations) != 1 || f.Reservations[0].Target != "user1" {
+		t.Fatalf("unexpected redacted reservations: %s", redacted)
+	}
+
+	if len(doc.Servers) != 1 || doc.Servers[0].Name != "host1" || !doc.Servers[0].Up {
+		t.Fatalf("unexpected redacted servers: %s", redacted)
+	}
+
+	if !bytes.Contains(redacted, []byte("Users of feature1:")) {
+		t.Fatalf("keyword redacted: %s", redacted)
+	}
+}
+
+func TestRecordOutput(t *testing.T) {
+	logger := promslog.New(&promslog.Config{})
+
+	recordDir := *lmutilRecordDir
+	*lmutilRecordDir = t.TempDir()
+
+	defer func() { *lmutilRecordDir = recordDir }()
+
+	now := time.Date(2026, 10, 19, 13, 25, 1, 0, time.UTC)
+	recordOutput(logger, "app1", []string{"lmstat", "-c", "27000@host1", "-i"}, []byte("output"), now)
+
+	out, err := os.ReadFile(filepath.Join(*lmutilRecordDir, "lmstat_i_app1_20261019T132501.000Z.txt"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if string(out) != "output" {
+		t.Fatalf("want output, got %q", out)
+	}
+}
+
+func TestRecordOutputKeep(t *testing.T) {
+	logger := promslog.New(&promslog.Config{})
+
+	recordDir, keep := *lmutilRecordDir, *lmutilRecordKeep
+	*lmutilRecordDir, *lmutilRecordKeep = t.TempDir(), 2
+
+	defer func() { *lmutilRecordDir, *lmutilRecordKeep = recordDir, keep }()
+
+	begin := time.Date(2026, 10, 19, 13, 25, 1, 0, time.UTC)
+
+	for i := range 4 {
+		now := begin.Add(time.Duration(i) * time.Minute)
+		recordOutput(logger, "app1", []string{"lmstat", "-c", "27000@host1", "-a"}, []byte("output"), now)
+		recordOutput(logger, "app1_x", []string{"lmstat", "-c", "27000@host2", "-a"}, []byte("output"), now)
+	}
+
+	recordOutput(logger, "app1", []string{"lmstat", "-c", "27000@host1", "-i"}, []byte("output"), begin)
+
+	entries, err := os.ReadDir(*lmutilRecordDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+
+	// The two newest files of each call are kept.
+	want := []string{
+		"lmstat_app1_20261019T132701.000Z.txt",
+		"lmstat_app1_20261019T132801.000Z.txt",
+		"lmstat_app1_x_20261019T132701.000Z.txt",
+		"lmstat_app1_x_20261019T132801.000Z.txt",
+		"lmstat_i_app1_20261019T132501.000Z.txt",
+	}
+	if !slices.Equal(names, want) {
+		t.Fatalf("want %v, got %v", want, names)
+	}
+}
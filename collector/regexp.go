@@ -3,50 +3,24 @@ package collector
 import "regexp"
 
 var (
-	// Regexp to parse lmutil output.
-	lmutilVersionRegex = regexp.MustCompile(
-		`^lmstat (?P<version>v[\d\.]+) build (?P<build>\d+) (?P<arch>[\w]+)`)
-	lmutilLicenseServersRegex = regexp.MustCompile(
-		`^License server status: (?P<servers>[\w,.@\-]+)`)
-	lmutilLicenseServerStatusRegex = regexp.MustCompile(
-		`(?P<fqdn>[\w\.\-]+): license server (?P<status>\w+)(?P<master>\s` +
-			`\(MASTER\))? (?P<version>v[\d\.]+)$`)
-	lmutilLicenseVendorStatusRegex = regexp.MustCompile(
-		`^\s+(?P<vendor>\w+): (?P<status>UP|DOWN) (?P<version>v[\d\.]+)$`)
-	lmutilLicenseFeatureUsageRegex = regexp.MustCompile(
-		`^Users of (?P<name>.*):\s+\(Total of (?P<issued>\d+) \w+ issued\;\s+` +
-			`Total of (?P<used>\d+) \w+ in use\)$`)
-	lmutilLicenseFeatureUsageUserRegex = regexp.MustCompile(
-		`^\s+(?P<user>[\w[:print:]]+) [\w\-\.]+ [[:print:]]+ (?P<ver>\(v[\w\.]+\)) \([\w\-\.]+\/\d+ ` +
-			`\d+\)\, start (?P<since>\w+ \d+\/\d+ \d+\:\d+)(\,\s(?P<licenses>\d+)\s\w+|)` +
-			`(\s+\(linger\:\s\d+\s\/\s\d+\))?` +
-			`(\,\s+PID\:\s+\d+\s?)?$`)
-	lmutilLicenseFeatureUsageUser2Regex = regexp.MustCompile(
-		`^\s+(?P<user>[\w[:print:]]+) [\w\-\.]+ (?P<ver>\(v[\w\.]+\)) \([\w\-\.]+\/\d+ ` +
-			`\d+\)\, start (?P<since>\w+ \d+\/\d+ \d+\:\d+)(\,\s(?P<licenses>\d+)\s\w+|)` +
-			`(\s+\(linger\:\s\d+\s\/\s\d+\))?$`)
-	lmutilLicenseFeatureUsageUserQueuedRegex = regexp.MustCompile(
-		`^\s+(?P<user>[\w[:print:]]+) [\w\-\.]+ [[:print:]]+ [0-9.]+ (?P<ver>\(v[\w\.]+\)) \([\w\-\.]+\/\d+ ` +
-			`\d+\)\s+queued for (?P<licenses>\d+) license[s]?$`)
-	lmutilLicenseFeatureGroupReservRegex = regexp.MustCompile(
-		`^(\s+|)(?P<reservation>\d+)\s+\w+\s+for\s+(HOST_GROUP|GROUP)\s+` +
-			`(?P<group>\w+).*$`)
-	lmutilLicenseFeatureHostReservRegex = regexp.MustCompile(
-		`^(\s+|)(?P<reservation>\d+)\s+\w+\s+for\s+(HOST)\s+` +
-			`(?P<host>\w+).*$`)
-	lmutilLicenseFeatureUsageNodeLockedRegex = regexp.MustCompile(
-		`^Users of (?P<name>.*):\s+\(Uncounted, node-locked\)$`)
-	lmutilLicenseFeatureTypeRegex = regexp.MustCompile(
-		`^\s+(?P<type>floating|uncounted nodelocked|nodelocked) license`)
-	// lmutil lmstat -c port@hostname -i.
-	lmutilLicenseFeatureExpRegex = regexp.MustCompile(
-		`^(?P<feature>[[:graph:]]+)\s+(?P<version>[\d\.]+)\s+` +
-			`(?P<licenses>\d+)\s+(?P<expires>[\w\-]+)\s+(?P<vendor>\w+)$`)
-	// lmutil lmstat -c port@hostname -i case with columns expired and vendors
-	// switched #28.
-	lmutilLicenseFeatureExpRegex2 = regexp.MustCompile(
-		`^(?P<feature>[[:graph:]]+)\s+(?P<version>[\d\.]+)\s+` +
-			`(?P<licenses>\d+)\s+(?P<vendor>\w+)\s+(?P<expires>[\w\-\s\(\)]+)$`)
+	// lmgrd and vendor daemon debug log.
+	debugLogUsageRegex = regexp.MustCompile(
+		`^\s*\d+:\d+:\d+ \((?P<vendor>[\w\-]+)\) (?P<event>OUT|IN|DENIED|UNSUPPORTED): ` +
+			`"?(?P<feature>[^"\s]+)"? \S+\s*(?P<rest>.*)$`)
+	debugLogDaemonRegex = regexp.MustCompile(
+		`^\s*(?P<time>\d+:\d+:\d+) \((?P<daemon>[\w\-]+)\) (?P<message>.*)$`)
+	debugLogDateRegex = regexp.MustCompile(
+		`(?:TIMESTAMP |\()(?P<date>\d+/\d+/\d{4})\)?`)
+	debugLogStartRegex = regexp.MustCompile(
+		`\bstarted on\b`)
+	debugLogShutdownRegex = regexp.MustCompile(
+		`\bEXITING DUE TO\b`)
+	debugLogRereadRegex = regexp.MustCompile(
+		`(?i)\bre-?read(ing)?\b`)
+	debugLogLostConnectionRegex = regexp.MustCompile(
+		`(?i)\blost connection\b`)
+	debugLogReasonRegex = regexp.MustCompile(
+		`\((?P<reason>[^()]+?)\s*\(-?\d+,-?\d+[^)]*\)\)`)
 	lmutilTimeRegex = regexp.MustCompile(
 		`^\w+ (?P<month>\d+)/(?P<day>\d+) (?P<time>\d+:\d+)$`)
 )
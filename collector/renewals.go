@@ -0,0 +1,229 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	kingpin "github.com/alecthomas/kingpin/v2"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+var lmstatFeatureExpRenewalsState = kingpin.Flag("collector.lmstat_feature_exp.renewals-state",
+	"JSON file where the expiry dates of the features are saved, to detect renewals across restarts.").
+	Default("").String()
+
+// renewalKey identifies the licenses of a feature and version.
+type renewalKey struct {
+	app     string
+	name    string
+	vendor  string
+	version string
+}
+
+// renewalEntry is the latest expiry date of a feature and version, in the
+// 2006-01-02 format or permanent, as saved in the state file.
+type renewalEntry struct {
+	App        string  `json:"app"`
+	Name       string  `json:"name"`
+	Vendor     string  `json:"vendor"`
+	Version    string  `json:"version"`
+	Expiry     string  `json:"expiry"`
+	Renewals   float64 `json:"renewals"`
+	LastChange int64   `json:"last_change,omitempty"`
+}
+
+// renewalTracker remembers the latest expiry date of each feature and version,
+// and counts the renewals, i.e. the times it moved later.
+type renewalTracker struct {
+	mtx        sync.Mutex
+	entries    map[renewalKey]*renewalEntry
+	path       string
+	renewals   *prometheus.Desc
+	lastChange *prometheus.Desc
+	logger     *slog.Logger
+}
+
+func newRenewalTracker(path string, logger *slog.Logger) (*renewalTracker, error) {
+	labels := []string{appString, nameString, "vendor", versionString}
+	t := &renewalTracker{
+		entries: make(map[renewalKey]*renewalEntry),
+		path:    path,
+		renewals: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature", "renewals_total"),
+			"License feature renewals, i.e. later expiry dates, labeled by app, name, vendor, version.",
+			labels, nil,
+		),
+		lastChange: prometheus.NewDesc(
+			prometheus.BuildFQName(namespace, "feature", "expiry_last_change_timestamp_seconds"),
+			"Time of the last license feature expiry date change labeled by app, name, vendor, version.",
+			labels, nil,
+		),
+		logger: logger,
+	}
+
+	if path == "" {
+		return t, nil
+	}
+
+	data, err := os.ReadFile(filepath.Clean(path))
+	if errors.Is(err, os.ErrNotExist) {
+		return t, nil
+	} else if err != nil {
+		return nil, fmt.Errorf("couldn't read renewals state file %s: %w", path, err)
+	}
+
+	var entries []*renewalEntry
+	if err := json.Unmarshal(data, &entries); err != nil {
+		return nil, fmt.Errorf("couldn't parse renewals state file %s: %w", path, err)
+	}
+
+	for _, e := range entries {
+		t.entries[renewalKey{app: e.App, name: e.Name, vendor: e.Vendor, version: e.Version}] = e
+	}
+
+	return t, nil
+}
+
+// expiryAfter returns whether the expiry date a is later than b.
+func expiryAfter(a, b string) bool {
+	if a == permanentString || b == permanentString {
+		return a == permanentString && b != permanentString
+	}
+
+	// The 2006-01-02 format sorts chronologically.
+	return a > b
+}
+
+// observe compares the latest expiry date of each feature and version of an
+// app with the previous one. The first expiry date of a feature and version
+// is only used as a baseline. The features and versions of the app that are
+// gone, and the apps no longer configured, are removed, unless the app has no
+// feature at all, e.g. after an lmstat error.
+func (t *renewalTracker) observe(app string, pools map[featureExpKey]*featureExpPool, now time.Time) {
+	latest := make(map[renewalKey]string)
+
+	for key := range pools {
+		k := renewalKey{app: app, name: key.name, vendor: key.vendor, version: key.version}
+		if expiry, ok := latest[k]; !ok || expiryAfter(key.expiry, expiry) {
+			latest[k] = key.expiry
+		}
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	changed := false
+
+	for k, expiry := range latest {
+		e, ok := t.entries[k]
+		if !ok {
+			t.entries[k] = &renewalEntry{App: k.app, Name: k.name, Vendor: k.vendor, Version: k.version, Expiry: expiry}
+			changed = true
+
+			continue
+		}
+
+		if e.Expiry == expiry {
+			continue
+		}
+
+		if expiryAfter(expiry, e.Expiry) {
+			e.Renewals++
+		}
+
+		t.logger.Info("license feature expiry changed", "app", app, "feature", k.name, "version", k.version,
+			"previous", e.Expiry, "expiry", expiry)
+
+		e.Expiry = expiry
+		e.LastChange = now.Unix()
+		changed = true
+	}
+
+	if len(latest) > 0 {
+		for k := range t.entries {
+			if _, ok := latest[k]; (k.app == app && !ok) || !configuredApp(k.app) {
+				delete(t.entries, k)
+				changed = true
+			}
+		}
+	}
+
+	if changed && t.path != "" {
+		if err := t.save(); err != nil {
+			t.logger.Warn("couldn't save renewals state", "path", t.path, "err", err)
+		}
+	}
+}
+
+// configuredApp returns whether an app is in the license configuration, or
+// true when it is not loaded.
+func configuredApp(app string) bool {
+	if len(LicenseConfig.Licenses) == 0 {
+		return true
+	}
+
+	for _, licenses := range LicenseConfig.Licenses {
+		if licenses.Name == app {
+			return true
+		}
+	}
+
+	return false
+}
+
+// save writes the state file, through a temporary file so that it is never
+// left half written.
+func (t *renewalTracker) save() error {
+	entries := make([]*renewalEntry, 0, len(t.entries))
+	for _, e := range t.entries {
+		entries = append(entries, e)
+	}
+
+	data, err := json.Marshal(entries)
+	if err != nil {
+		return err
+	}
+
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(filepath.Clean(tmp), data, 0o600); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp, t.path)
+}
+
+// collect sends the renewals and the last expiry date change of each feature
+// and version.
+func (t *renewalTracker) collect(ch chan<- prometheus.Metric) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	for _, e := range t.entries {
+		ch <- prometheus.MustNewConstMetric(t.renewals, prometheus.CounterValue, e.Renewals,
+			e.App, e.Name, e.Vendor, e.Version)
+
+		if e.LastChange > 0 {
+			ch <- prometheus.MustNewConstMetric(t.lastChange, prometheus.GaugeValue, float64(e.LastChange),
+				e.App, e.Name, e.Vendor, e.Version)
+		}
+	}
+}
@@ -0,0 +1,102 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/prometheus/common/promslog"
+)
+
+func TestRenewalTrackerObserve(t *testing.T) {
+	t.Parallel()
+
+	logger := promslog.New(&promslog.Config{})
+	path := filepath.Join(t.TempDir(), "renewals.json")
+
+	tracker, err := newRenewalTracker(path, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	now := time.Unix(1700000000, 0)
+	key := renewalKey{app: "app1", name: feature12String, vendor: vendor2String, version: v201812String}
+	pools := func(expiries ...string) map[featureExpKey]*featureExpPool {
+		p := make(map[featureExpKey]*featureExpPool)
+		for _, expiry := range expiries {
+			p[featureExpKey{name: key.name, vendor: key.vendor, version: key.version, expiry: expiry}] = &featureExpPool{}
+		}
+
+		return p
+	}
+
+	// The first expiry date is only the baseline, and the latest one is kept.
+	tracker.observe("app1", pools("2018-09-30", "2018-12-31"), now)
+
+	if e := tracker.entries[key]; e.Expiry != "2018-12-31" || e.Renewals != 0 || e.LastChange != 0 {
+		t.Fatalf("Unexpected baseline %+v", e)
+	}
+
+	tracker.observe("app1", pools("2019-12-31"), now)
+
+	if e := tracker.entries[key]; e.Expiry != "2019-12-31" || e.Renewals != 1 || e.LastChange != now.Unix() {
+		t.Fatalf("Unexpected renewal %+v", e)
+	}
+
+	// An earlier expiry date is a change, but not a renewal.
+	tracker.observe("app1", pools("2019-06-30"), now.Add(time.Hour))
+
+	if e := tracker.entries[key]; e.Renewals != 1 || e.LastChange != now.Add(time.Hour).Unix() {
+		t.Fatalf("Unexpected change %+v", e)
+	}
+
+	tracker.observe("app1", pools(permanentString), now)
+
+	// The state is restored from the state file.
+	restored, err := newRenewalTracker(path, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if e := restored.entries[key]; e == nil || e.Expiry != permanentString || e.Renewals != 2 {
+		t.Fatalf("Unexpected restored state %+v", e)
+	}
+
+	// An app without features, e.g. after an lmstat error, keeps its state.
+	restored.observe("app1", pools(), now)
+
+	if restored.entries[key] == nil {
+		t.Fatal("Unexpected removed state without features")
+	}
+
+	// A feature gone from the license file is removed, and so from the state
+	// file.
+	other := featureExpKey{name: "feature99", vendor: key.vendor, version: key.version, expiry: "2030-01-01"}
+	restored.observe("app1", map[featureExpKey]*featureExpPool{other: {}}, now)
+
+	if restored.entries[key] != nil || len(restored.entries) != 1 {
+		t.Fatalf("Unexpected state after the feature is gone %v", restored.entries)
+	}
+
+	restored, err = newRenewalTracker(path, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if restored.entries[key] != nil || len(restored.entries) != 1 {
+		t.Fatalf("Unexpected saved state after the feature is gone %v", restored.entries)
+	}
+}
@@ -0,0 +1,89 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	kingpin "github.com/alecthomas/kingpin/v2"
+)
+
+// The directory of the saved lmstat output files replayed instead of calling
+// lmutil.
+var lmutilReplayDir = kingpin.Flag("lmutil.replay-dir",
+	"Directory of saved lmstat output files, lmstat_<app>.txt for -a, lmstat_i_<app>.txt for -i, "+
+		"lmstat_f_<feature>_<app>.txt for -f, lmstat_S_<vendor>_<app>.txt for -S, lmstat_status_<app>.txt "+
+		"without options and lmstat_v.txt for -v, read instead of calling lmutil.").Default("").String()
+
+// replayFileName returns the name of the saved output file of an lmstat
+// call, e.g. lmstat_app1.txt for -a, lmstat_i_app1.txt for -i,
+// lmstat_f_feature1_app1.txt for -f feature1, lmstat_status_app1.txt without
+// options and lmstat_v.txt for -v.
+func replayFileName(app string, args []string) string {
+	options := make([]string, 0, len(args))
+
+	for i := 0; i < len(args); i++ {
+		switch args[i] {
+		case "lmstat":
+		case "-c":
+			// Skip the license file or server.
+			i++
+		default:
+			options = append(options, strings.TrimPrefix(args[i], "-"))
+		}
+	}
+
+	parts := []string{"lmstat"}
+
+	switch strings.Join(options, " ") {
+	case "a":
+		// lmstat -a is the default.
+	case "":
+		parts = append(parts, "status")
+	default:
+		parts = append(parts, options...)
+	}
+
+	if !slices.Equal(options, []string{"v"}) {
+		parts = append(parts, app)
+	}
+
+	return strings.Join(parts, "_") + ".txt"
+}
+
+// replayOutput reads the saved lmstat output of a license from
+// --lmutil.replay-dir. A missing lmstat -v output is not an error, as it is
+// seldom captured.
+func replayOutput(logger *slog.Logger, app string, args []string) ([]byte, error) {
+	path := filepath.Join(*lmutilReplayDir, replayFileName(app, args))
+
+	out, err := os.ReadFile(filepath.Clean(path))
+	if err != nil {
+		if errors.Is(err, os.ErrNotExist) && app == "" {
+			logger.Debug("no saved lmstat output to replay", "path", path)
+
+			return nil, nil
+		}
+
+		return nil, fmt.Errorf("couldn't replay '%s': %w", strings.Join(args, " "), err)
+	}
+
+	return out, nil
+}
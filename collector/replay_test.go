@@ -0,0 +1,86 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"bytes"
+	"os"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+)
+
+func TestReplayOutput(t *testing.T) {
+	logger := promslog.New(&promslog.Config{})
+
+	replayDir := *lmutilReplayDir
+	*lmutilReplayDir = "fixtures"
+
+	defer func() { *lmutilReplayDir = replayDir }()
+
+	for _, tc := range []struct {
+		args    []string
+		fixture string
+	}{
+		{[]string{"lmstat", "-c", "27000@host1", "-a"}, "fixtures/lmstat_app1.txt"},
+		{[]string{"lmstat", "-c", "27000@host1", "-i"}, "fixtures/lmstat_i_app1.txt"},
+	} {
+		out, err := lmutilOutput(logger, "app1", tc.args...)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		want, err := os.ReadFile(tc.fixture)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if !bytes.Equal(out, want) {
+			t.Fatalf("%v: want %s content", tc.args, tc.fixture)
+		}
+	}
+
+	// There is no saved lmstat -v output.
+	out, err := lmutilOutput(logger, "", "lmstat", "-v")
+	if err != nil || len(out) != 0 {
+		t.Fatalf("want no lmstat -v output, got %q, %v", out, err)
+	}
+
+	_, err = lmutilOutput(logger, "app8", "lmstat", "-c", "27000@host1", "-a")
+	if err == nil {
+		t.Fatal("want error for a license without saved output")
+	}
+}
+
+func TestReplayFileName(t *testing.T) {
+	t.Parallel()
+
+	for want, args := range map[string][]string{
+		"lmstat_app1.txt":            {"lmstat", "-c", "27000@host1", "-a"},
+		"lmstat_i_app1.txt":          {"lmstat", "-c", "/etc/license.dat", "-i"},
+		"lmstat_f_feature1_app1.txt": {"lmstat", "-c", "27000@host1", "-f", "feature1"},
+		"lmstat_S_VENDOR1_app1.txt":  {"lmstat", "-c", "27000@host1", "-S", "VENDOR1"},
+		"lmstat_status_app1.txt":     {"lmstat", "-c", "27000@host1"},
+		"lmstat_v.txt":               {"lmstat", "-v"},
+	} {
+		app := "app1"
+		if args[1] == "-v" {
+			app = ""
+		}
+
+		if got := replayFileName(app, args); got != want {
+			t.Fatalf("%v: want %s, got %s", args, want, got)
+		}
+	}
+}
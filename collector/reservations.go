@@ -0,0 +1,150 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"strings"
+
+	"github.com/mjtrangoni/flexlm_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// groupReservation is the reservation of a feature for a group.
+type groupReservation struct {
+	count float64
+	// unused is set when count comes from lmstat, which only reports the
+	// reserved licenses not in use.
+	unused bool
+}
+
+// isGroupReservation returns whether a reservation is for a GROUP or a
+// HOST_GROUP.
+func isGroupReservation(r featureReservation) bool {
+	return r.targetType == strings.ToLower(optionsGroup) || r.targetType == strings.ToLower(optionsHostGroup)
+}
+
+// reservationGroups returns the licenses reserved by group of a feature. The
+// groups are identified by their type and name, since a GROUP and a HOST_GROUP
+// can share a name. The RESERVE lines of the options file take precedence over
+// the reservations reported by lmstat, as lmstat only reports the reservations
+// not in use.
+func reservationGroups(name string, of *optionsFile,
+	lmstatReserved map[featureReservation]float64) map[featureReservation]groupReservation {
+	reserved := make(map[featureReservation]groupReservation)
+
+	if of != nil {
+		for _, r := range of.reservations {
+			group := featureReservation{targetType: r.targetType, target: r.target}
+			if r.feature == name && isGroupReservation(group) {
+				reserved[group] = groupReservation{count: reserved[group].count + r.count}
+			}
+		}
+	}
+
+	for group, count := range lmstatReserved {
+		if _, ok := reserved[group]; !ok && isGroupReservation(group) {
+			reserved[group] = groupReservation{count: count, unused: true}
+		}
+	}
+
+	return reserved
+}
+
+// reservationUsage returns the licenses used by the members of each group. It
+// is capped by the licenses reserved for the group in the options file. The
+// reservations only known from lmstat are the unused ones, so the reserved
+// total is the unused licenses plus the usage of the members, which is then
+// never capped. A session belongs to a GROUP when its user is a member, or is
+// mapped to the group with `user_groups`, and to a HOST_GROUP when its host is
+// a member.
+func reservationUsage(reserved map[featureReservation]groupReservation, of *optionsFile, userGroups map[string]string,
+	sessions []*featureSession) map[featureReservation]float64 {
+	used := make(map[featureReservation]float64, len(reserved))
+
+	for group, reservation := range reserved {
+		members := make(map[string]bool)
+
+		if group.targetType == strings.ToLower(optionsHostGroup) {
+			if of != nil {
+				for _, host := range of.hostGroups[group.target] {
+					members[strings.ToLower(host)] = true
+				}
+			}
+		} else {
+			if of != nil {
+				for _, user := range of.groups[group.target] {
+					members[user] = true
+				}
+			}
+
+			for user, userGroup := range userGroups {
+				if userGroup == group.target {
+					members[user] = true
+				}
+			}
+		}
+
+		used[group] = 0
+
+		for _, s := range sessions {
+			member := members[s.user]
+			if group.targetType == strings.ToLower(optionsHostGroup) {
+				member = members[strings.ToLower(s.host)]
+			}
+
+			if member {
+				used[group] += s.licenses
+			}
+		}
+
+		if !reservation.unused {
+			used[group] = min(used[group], reservation.count)
+		}
+	}
+
+	return used
+}
+
+// collectReservationUsage sends the licenses used from the reservation of each
+// group, for the groups with known members.
+func (c *lmstatCollector) collectReservationUsage(licenses *config.License, of *optionsFile,
+	reservations map[string]map[featureReservation]float64, sessionsByFeature map[string][]*featureSession,
+	filtered func(name string) bool, ch chan<- prometheus.Metric) {
+	if of == nil && len(licenses.UserGroups) == 0 {
+		return
+	}
+
+	names := make(map[string]bool)
+	for name := range reservations {
+		names[name] = true
+	}
+
+	if of != nil {
+		for name := range of.reservedByFeature() {
+			names[name] = true
+		}
+	}
+
+	for name := range names {
+		if filtered(name) {
+			continue
+		}
+
+		reserved := reservationGroups(name, of, reservations[name])
+		for group, used := range reservationUsage(reserved, of, licenses.UserGroups, sessionsByFeature[name]) {
+			ch <- prometheus.MustNewConstMetric(c.lmstatFeatureReservationUsed,
+				prometheus.GaugeValue, used, licenses.Name, name, group.targetType, group.target)
+		}
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/prometheus/common/promslog"
+)
+
+func TestReservationUsage(t *testing.T) {
+	t.Parallel()
+
+	logger := promslog.New(&promslog.Config{})
+
+	of, err := readOptionsFile(testOptionsFile1, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// The options file reserves 2 licenses of feature1 for engineers, and
+	// lmstat reports a reservation for GROUP1 only.
+	engineers := featureReservation{targetType: "group", target: "engineers"}
+	group1 := featureReservation{targetType: "group", target: "GROUP1"}
+
+	reserved := reservationGroups("feature1", of, map[featureReservation]float64{engineers: 1, group1: 3})
+	if !reflect.DeepEqual(reserved, map[featureReservation]groupReservation{
+		engineers: {count: 2}, group1: {count: 3, unused: true},
+	}) {
+		t.Fatalf("Unexpected reserved licenses: %v", reserved)
+	}
+
+	sessions := []*featureSession{
+		{user: "user1", host: "host9", licenses: 1},
+		{user: "user4", host: "host9", licenses: 2},
+		{user: "user9", host: "host9", licenses: 1},
+	}
+
+	used := reservationUsage(reserved, of, map[string]string{"user9": "GROUP1"}, sessions)
+	if !reflect.DeepEqual(used, map[featureReservation]float64{engineers: 2, group1: 1}) {
+		t.Fatalf("Unexpected used reservations: %v", used)
+	}
+
+	// lmstat reports 1 unused license of the GROUP1 reservation, while its
+	// members use 2, which are all counted.
+	reserved = reservationGroups("feature1", of, map[featureReservation]float64{group1: 1})
+	sessions = []*featureSession{
+		{user: "user9", host: "host9", licenses: 1},
+		{user: "user9", host: "host8", licenses: 1},
+	}
+
+	used = reservationUsage(reserved, of, map[string]string{"user9": "GROUP1"}, sessions)
+	if used[group1] != 2 {
+		t.Fatalf("Unexpected used GROUP1 reservation: %v != 2", used[group1])
+	}
+
+	// A fully used reservation is no longer reported by lmstat, the options
+	// file still defines it.
+	reserved = reservationGroups("feature1", of, nil)
+	sessions = []*featureSession{
+		{user: "user1", host: "host9", licenses: 1},
+		{user: "user4", host: "host9", licenses: 1},
+	}
+
+	used = reservationUsage(reserved, of, nil, sessions)
+	if !reflect.DeepEqual(used, map[featureReservation]float64{engineers: 2}) {
+		t.Fatalf("Unexpected used fully consumed reservation: %v", used)
+	}
+
+	// Host groups match the session hosts.
+	reserved = reservationGroups("feature2", of, nil)
+	sessions = []*featureSession{{user: "user1", host: "HOST2.domain.net", licenses: 1}}
+
+	used = reservationUsage(reserved, of, nil, sessions)
+	if !reflect.DeepEqual(used, map[featureReservation]float64{{targetType: "host_group", target: "workstations"}: 1}) {
+		t.Fatalf("Unexpected used host group reservations: %v", used)
+	}
+}
+
+func TestReservationUsageSameGroupName(t *testing.T) {
+	t.Parallel()
+
+	logger := promslog.New(&promslog.Config{})
+
+	path := filepath.Join(t.TempDir(), "options.opt")
+	if err := os.WriteFile(path, []byte(`GROUP lab user1 user2
+HOST_GROUP lab host1 host2
+RESERVE 2 feature1 GROUP lab
+RESERVE 3 feature1 HOST_GROUP lab
+`), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	of, err := readOptionsFile(path, logger)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	users := featureReservation{targetType: "group", target: "lab"}
+	hosts := featureReservation{targetType: "host_group", target: "lab"}
+
+	reserved := reservationGroups("feature1", of, nil)
+	if !reflect.DeepEqual(reserved, map[featureReservation]groupReservation{users: {count: 2}, hosts: {count: 3}}) {
+		t.Fatalf("Unexpected reserved licenses: %v", reserved)
+	}
+
+	// The users of the GROUP don't count for the HOST_GROUP, and the hosts of
+	// the HOST_GROUP don't count for the GROUP.
+	sessions := []*featureSession{
+		{user: "user1", host: "host9", licenses: 1},
+		{user: "user9", host: "host1", licenses: 2},
+		{user: "user9", host: "host9", licenses: 4},
+	}
+
+	used := reservationUsage(reserved, of, nil, sessions)
+	if !reflect.DeepEqual(used, map[featureReservation]float64{users: 1, hosts: 2}) {
+		t.Fatalf("Unexpected used reservations: %v", used)
+	}
+}
@@ -0,0 +1,118 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	scrapeErrorBackoff = "backoff"
+	scrapeErrorError   = "error"
+)
+
+var (
+	scrapeErrorInfoDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "scrape", "error_info"),
+		"flexlm_exporter: Latest error of a license scrape, kept until the next success, "+
+			"labeled by collector, app, FLEXlm error code and description.",
+		[]string{collectorString, appString, "code", "description"},
+		nil,
+	)
+	lastSuccessfulScrapeDesc = prometheus.NewDesc(
+		prometheus.BuildFQName(namespace, "", "last_successful_scrape_timestamp_seconds"),
+		"flexlm_exporter: Time of the last successful license scrape.",
+		[]string{collectorString, appString},
+		nil,
+	)
+
+	// scrapeStatus keeps the latest error and success of the licenses of all
+	// the collectors.
+	scrapeStatus = newScrapeStatusTracker()
+)
+
+// scrapeErrorStatus is the latest error of a license scrape.
+type scrapeErrorStatus struct {
+	code        string
+	description string
+}
+
+type scrapeStatusTracker struct {
+	mtx         sync.Mutex
+	errors      map[breakerKey]scrapeErrorStatus
+	lastSuccess map[breakerKey]time.Time
+}
+
+func newScrapeStatusTracker() *scrapeStatusTracker {
+	return &scrapeStatusTracker{
+		errors:      make(map[breakerKey]scrapeErrorStatus),
+		lastSuccess: make(map[breakerKey]time.Time),
+	}
+}
+
+// scrapeErrorCode returns the FLEXlm error code and description of an lmutil
+// error. The other errors get a fixed description, as their text may contain
+// paths or other variable values, which are only logged.
+func scrapeErrorCode(err error) scrapeErrorStatus {
+	var lmutilErr *lmutilError
+
+	switch {
+	case errors.As(err, &lmutilErr):
+		return scrapeErrorStatus{code: lmutilErr.code, description: lmutilErr.description}
+	case errors.Is(err, errBackoff):
+		return scrapeErrorStatus{code: scrapeErrorBackoff, description: errBackoff.Error()}
+	default:
+		return scrapeErrorStatus{code: scrapeErrorError, description: "exporter error, see the logs"}
+	}
+}
+
+// observe records the result of a license scrape. A backoff keeps the error
+// that caused it.
+func (t *scrapeStatusTracker) observe(key breakerKey, err error, now time.Time) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if err == nil {
+		delete(t.errors, key)
+		t.lastSuccess[key] = now
+
+		return
+	}
+
+	if _, ok := t.errors[key]; ok && errors.Is(err, errBackoff) {
+		return
+	}
+
+	t.errors[key] = scrapeErrorCode(err)
+}
+
+// send sends the latest error and success of a license scrape.
+func (t *scrapeStatusTracker) send(ch chan<- prometheus.Metric, key breakerKey) {
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	if status, ok := t.errors[key]; ok {
+		ch <- prometheus.MustNewConstMetric(scrapeErrorInfoDesc, prometheus.GaugeValue, 1.0,
+			key.collector, key.name, status.code, status.description)
+	}
+
+	if last, ok := t.lastSuccess[key]; ok {
+		ch <- prometheus.MustNewConstMetric(lastSuccessfulScrapeDesc, prometheus.GaugeValue, float64(last.Unix()),
+			key.collector, key.name)
+	}
+}
@@ -0,0 +1,73 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"errors"
+	"fmt"
+	"os/exec"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestScrapeStatusTracker(t *testing.T) {
+	t.Parallel()
+
+	runErr := exec.Command("sh", "-c", "exit 241").Run()
+	lmutilErr := newLmutilError([]string{"lmstat", "-c", "27000@host1", "-a"}, runErr)
+
+	if !strings.HasPrefix(lmutilErr.description, "Cannot connect to license server.") || lmutilErr.code != "-15" {
+		t.Fatalf("Unexpected lmutil error %s, %s", lmutilErr.code, lmutilErr.description)
+	}
+
+	tracker := newScrapeStatusTracker()
+	key := breakerKey{collector: "lmstat", name: "app1"}
+	now := time.Now()
+
+	tracker.observe(key, nil, now)
+	tracker.observe(key, fmt.Errorf("couldn't get license: %w", lmutilErr), now.Add(time.Minute))
+
+	if status := tracker.errors[key]; status.code != "-15" || status.description != lmutilErr.description {
+		t.Fatalf("Unexpected error status %+v", status)
+	}
+
+	// A backoff keeps the error that caused it.
+	tracker.observe(key, errBackoff, now.Add(2*time.Minute))
+
+	if status := tracker.errors[key]; status.code != "-15" {
+		t.Fatalf("Unexpected error status after backoff %+v", status)
+	}
+
+	if !tracker.lastSuccess[key].Equal(now) {
+		t.Fatalf("want last success at %s, got %s", now, tracker.lastSuccess[key])
+	}
+
+	// The error is kept until the next success.
+	tracker.observe(key, nil, now.Add(3*time.Minute))
+
+	if _, ok := tracker.errors[key]; ok || !tracker.lastSuccess[key].Equal(now.Add(3*time.Minute)) {
+		t.Fatal("want error cleared on success")
+	}
+
+	// The text of the other errors, e.g. with a path, is not a label value.
+	status := scrapeErrorCode(errors.New("couldn't read /var/lib/flexlm/app1.lic"))
+	if status.code != "error" || status.description != scrapeErrorCode(errors.New("config error")).description {
+		t.Fatalf("Unexpected error status %+v", status)
+	}
+
+	if status := scrapeErrorCode(errBackoff); status.code != "backoff" || status.description != errBackoff.Error() {
+		t.Fatalf("Unexpected backoff status %+v", status)
+	}
+}
@@ -0,0 +1,133 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"sync"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+const (
+	sessionBucketStart  = 60
+	sessionBucketFactor = 4
+	sessionBucketCount  = 8
+)
+
+// sessionKey identifies a single checkout as reported by lmstat.
+type sessionKey struct {
+	user   string
+	host   string
+	handle string
+	since  int64
+}
+
+// sessionTracker keeps the previous snapshot of sessions by app and feature,
+// and derives checkouts, check-ins and session durations by comparing it
+// with the current one.
+type sessionTracker struct {
+	mtx       sync.Mutex
+	previous  map[string]map[string]map[sessionKey]struct{}
+	checkouts *prometheus.CounterVec
+	checkins  *prometheus.CounterVec
+	durations *prometheus.HistogramVec
+}
+
+func newSessionTracker() *sessionTracker {
+	return &sessionTracker{
+		previous: make(map[string]map[string]map[sessionKey]struct{}),
+		checkouts: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "feature",
+			Name:      "checkouts_total",
+			Help:      "License feature checkouts seen between two lmstat snapshots labeled by app and feature name.",
+		}, []string{appString, nameString}),
+		checkins: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: namespace,
+			Subsystem: "feature",
+			Name:      "checkins_total",
+			Help:      "License feature check-ins seen between two lmstat snapshots labeled by app and feature name.",
+		}, []string{appString, nameString}),
+		durations: prometheus.NewHistogramVec(prometheus.HistogramOpts{
+			Namespace: namespace,
+			Subsystem: "feature",
+			Name:      "session_duration_seconds",
+			Help:      "Duration of completed license feature sessions labeled by app and feature name.",
+			Buckets:   prometheus.ExponentialBuckets(sessionBucketStart, sessionBucketFactor, sessionBucketCount),
+		}, []string{appString, nameString}),
+	}
+}
+
+// observe compares the sessions of an app with the previous snapshot. The
+// first snapshot of an app is only used as a baseline. The features missing
+// from the lmstat output, e.g. after a timeout or a vendor daemon restart,
+// keep their previous sessions, so that they are not all counted as check-ins
+// and then as checkouts again.
+func (t *sessionTracker) observe(app string, features map[string]*feature, sessionsByFeature map[string][]*featureSession,
+	now time.Time) {
+	current := make(map[string]map[sessionKey]struct{}, len(sessionsByFeature))
+
+	for name, sessions := range sessionsByFeature {
+		current[name] = make(map[sessionKey]struct{}, len(sessions))
+		for _, s := range sessions {
+			current[name][sessionKey{user: s.user, host: s.host, handle: s.handle, since: s.since}] = struct{}{}
+		}
+	}
+
+	t.mtx.Lock()
+	defer t.mtx.Unlock()
+
+	previous, ok := t.previous[app]
+	t.previous[app] = current
+
+	if !ok {
+		return
+	}
+
+	for name, sessions := range previous {
+		if _, found := features[name]; !found {
+			current[name] = sessions
+		}
+	}
+
+	for name, sessions := range current {
+		for key := range sessions {
+			if _, found := previous[name][key]; !found {
+				t.checkouts.WithLabelValues(app, name).Inc()
+			}
+		}
+	}
+
+	for name, sessions := range previous {
+		for key := range sessions {
+			if _, found := current[name][key]; found {
+				continue
+			}
+
+			t.checkins.WithLabelValues(app, name).Inc()
+
+			if duration := now.Unix() - key.since; duration >= 0 {
+				t.durations.WithLabelValues(app, name).Observe(float64(duration))
+			}
+		}
+	}
+}
+
+// collect sends the session metrics to the channel.
+func (t *sessionTracker) collect(ch chan<- prometheus.Metric) {
+	t.checkouts.Collect(ch)
+	t.checkins.Collect(ch)
+	t.durations.Collect(ch)
+}
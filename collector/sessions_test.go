@@ -0,0 +1,79 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package collector
+
+import (
+	"testing"
+	"time"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+)
+
+func TestSessionTrackerObserve(t *testing.T) {
+	t.Parallel()
+
+	now := time.Now()
+	tracker := newSessionTracker()
+	user1 := &featureSession{user: "user1", host: "host1", handle: "100", since: now.Add(-time.Hour).Unix()}
+	user2 := &featureSession{user: "user2", host: "host2", handle: "200", since: now.Unix()}
+	features := map[string]*feature{"feature1": {}}
+
+	// The first snapshot is only the baseline.
+	tracker.observe("app1", features, map[string][]*featureSession{"feature1": {user1}}, now)
+
+	if count := testutil.CollectAndCount(tracker.checkouts); count != 0 {
+		t.Fatalf("Unexpected checkouts after the first snapshot: %d != 0", count)
+	}
+
+	tracker.observe("app1", features, map[string][]*featureSession{"feature1": {user2}}, now)
+
+	if value := testutil.ToFloat64(tracker.checkouts.WithLabelValues("app1", "feature1")); value != 1 {
+		t.Fatalf("Unexpected checkouts for feature1: %v != 1", value)
+	}
+
+	if value := testutil.ToFloat64(tracker.checkins.WithLabelValues("app1", "feature1")); value != 1 {
+		t.Fatalf("Unexpected checkins for feature1: %v != 1", value)
+	}
+
+	if count := testutil.CollectAndCount(tracker.durations); count != 1 {
+		t.Fatalf("Unexpected session duration series: %d != 1", count)
+	}
+
+	// Unchanged sessions are neither checked out nor checked in again.
+	tracker.observe("app1", features, map[string][]*featureSession{"feature1": {user2}}, now)
+
+	if value := testutil.ToFloat64(tracker.checkouts.WithLabelValues("app1", "feature1")); value != 1 {
+		t.Fatalf("Unexpected checkouts for feature1: %v != 1", value)
+	}
+
+	// A snapshot without feature1, e.g. after an lmstat timeout, keeps its
+	// sessions instead of checking them in.
+	tracker.observe("app1", map[string]*feature{}, map[string][]*featureSession{}, now)
+	tracker.observe("app1", features, map[string][]*featureSession{"feature1": {user2}}, now)
+
+	if value := testutil.ToFloat64(tracker.checkins.WithLabelValues("app1", "feature1")); value != 1 {
+		t.Fatalf("Unexpected checkins for feature1 after a partial snapshot: %v != 1", value)
+	}
+
+	if value := testutil.ToFloat64(tracker.checkouts.WithLabelValues("app1", "feature1")); value != 1 {
+		t.Fatalf("Unexpected checkouts for feature1 after a partial snapshot: %v != 1", value)
+	}
+
+	// A feature still in the output without sessions checks them in.
+	tracker.observe("app1", features, map[string][]*featureSession{}, now)
+
+	if value := testutil.ToFloat64(tracker.checkins.WithLabelValues("app1", "feature1")); value != 2 {
+		t.Fatalf("Unexpected checkins for feature1: %v != 2", value)
+	}
+}
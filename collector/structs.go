@@ -14,6 +14,8 @@
 
 package collector
 
+import "time"
+
 const (
 	licenseTypeFloating   = "floating"
 	licenseTypeNodeLocked = "node-locked"
@@ -51,6 +53,25 @@ type featureUserUsed struct {
 	since   string
 }
 
+type featurePool struct {
+	name    string
+	version string
+	expiry  string
+}
+
+type featureReservation struct {
+	targetType string
+	target     string
+}
+
+type featureSession struct {
+	user     string
+	host     string
+	handle   string
+	since    int64
+	licenses float64
+}
+
 type featureExp struct {
 	name     string
 	expires  float64
@@ -59,8 +80,98 @@ type featureExp struct {
 	version  string
 }
 
+// featureExpKey identifies the lmstat -i or license file lines of a feature
+// with the same vendor, version and expiry date.
+type featureExpKey struct {
+	name    string
+	vendor  string
+	version string
+	expiry  string
+}
+
+type featureExpPool struct {
+	expires  float64
+	licenses int
+}
+
+type expiryWindow struct {
+	label    string
+	duration time.Duration
+}
+
+type expiryCount struct {
+	features int
+	licenses int
+}
+
 type aggrFeaturesExp struct {
 	app      string
+	expires  float64
 	features int
 	licenses int
 }
+
+type licenseFile struct {
+	servers  []*licenseServer
+	vendors  []*licenseVendor
+	features []*licenseFeature
+	packages []*licensePackage
+}
+
+type licenseServer struct {
+	host   string
+	hostid string
+	port   string
+}
+
+type licenseVendor struct {
+	name    string
+	path    string
+	port    string
+	options string
+}
+
+type licenseFeature struct {
+	lineType   string
+	name       string
+	vendor     string
+	version    string
+	expires    float64
+	start      float64
+	count      float64
+	uncounted  bool
+	nodeLocked bool
+	vendorInfo string
+	issuer     string
+	sn         string
+	hostid     string
+}
+
+type licensePackage struct {
+	name       string
+	vendor     string
+	version    string
+	components []string
+}
+
+type optionsFile struct {
+	groups       map[string][]string
+	hostGroups   map[string][]string
+	reservations []*optionsLimit
+	maxes        []*optionsLimit
+	rules        []*optionsRule
+}
+
+type optionsLimit struct {
+	feature    string
+	targetType string
+	target     string
+	count      float64
+}
+
+type optionsRule struct {
+	rule       string
+	feature    string
+	targetType string
+	target     string
+}
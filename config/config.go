@@ -27,14 +27,21 @@ import (
 
 // License individual configuration type.
 type License struct {
-	Name                string `yaml:"name"`
-	LicenseFile         string `yaml:"license_file,omitempty"`
-	LicenseServer       string `yaml:"license_server,omitempty"`
-	FeaturesToExclude   string `yaml:"features_to_exclude,omitempty"`
-	FeaturesToInclude   string `yaml:"features_to_include,omitempty"`
+	Name              string `yaml:"name"`
+	LicenseFile       string `yaml:"license_file,omitempty"`
+	LicenseServer     string `yaml:"license_server,omitempty"`
+	FeaturesToExclude string `yaml:"features_to_exclude,omitempty"`
+	FeaturesToInclude string `yaml:"features_to_include,omitempty"`
+	// Vendor restricts lmstat to the features of a vendor daemon.
+	Vendor              string `yaml:"vendor,omitempty"`
 	MonitorUsers        bool   `yaml:"monitor_users"`
 	MonitorReservations bool   `yaml:"monitor_reservations"`
 	MonitorVersions     bool   `yaml:"monitor_versions,omitempty"`
+	DebugLog            string `yaml:"debug_log,omitempty"`
+	OptionsFile         string `yaml:"options_file,omitempty"`
+	// UserGroups maps usernames to reservation groups, for the groups that
+	// are not defined in the options file.
+	UserGroups map[string]string `yaml:"user_groups,omitempty"`
 }
 
 // Configuration type for all licenses.
@@ -15,6 +15,7 @@
 package main
 
 import (
+	"context"
 	"fmt"
 	"log/slog"
 	"net/http"
@@ -24,8 +25,10 @@ import (
 	//nolint:gosec
 	_ "net/http/pprof"
 	"os"
+	"os/signal"
 	"os/user"
 	"sort"
+	"syscall"
 
 	"github.com/prometheus/common/promslog"
 	"github.com/prometheus/common/promslog/flag"
@@ -144,6 +147,12 @@ func (h *handler) innerHandler(filters ...string) (http.Handler, error) {
 		os.Exit(1)
 	}
 
+	// Only the unfiltered handler polls in the background, for as long as the
+	// exporter serves.
+	if len(filters) == 0 {
+		nc.StartPolling(context.Background())
+	}
+
 	r := prometheus.NewRegistry()
 	r.MustRegister(promcollectorsversion.NewCollector("flexlm_exporter"))
 
@@ -191,6 +200,23 @@ func main() {
 			"runtime.gomaxprocs", "The target number of CPUs Go will run on (GOMAXPROCS)",
 		).Envar("GOMAXPROCS").Default("1").Int()
 		toolkitFlags = kingpinflag.AddFlags(kingpin.CommandLine, ":9319")
+
+		onceCmd    = kingpin.Command("once", "Run the enabled collectors once, print the metrics to stdout and exit.")
+		onceFormat = onceCmd.Flag("format", "Output format: text, openmetrics or json.").
+				Default(formatText).Enum(formatText, formatOpenMetrics, formatJSON)
+
+		pushCmd = kingpin.Command("push",
+			"Run the enabled collectors on an interval and push the metrics to a Pushgateway or a remote_write endpoint.")
+		pushgatewayURL = pushCmd.Flag("pushgateway-url", "Pushgateway URL, the metrics of each app are pushed to their own group.").
+				String()
+		remoteWriteURL = pushCmd.Flag("remote-write-url", "Prometheus remote_write endpoint URL.").String()
+		pushJob        = pushCmd.Flag("job", "Job label of the pushed metrics.").Default("flexlm_exporter").String()
+		pushInterval   = pushCmd.Flag("interval", "Interval between two pushes.").Default("1m").Duration()
+		pushTimeout    = pushCmd.Flag("timeout", "Timeout of a push request.").Default("30s").Duration()
+		pushRetries    = pushCmd.Flag("retries", "Retries of a failed push, with a doubling backoff.").Default("3").Int()
+		pushBackoff    = pushCmd.Flag("retry-backoff", "Initial backoff between the retries of a push.").Default("5s").Duration()
+		pushBufferSize = pushCmd.Flag("buffer-size",
+			"remote_write batches kept while the endpoint is unreachable, the oldest are dropped first.").Default("60").Int()
 	)
 
 	promslogConfig := &promslog.Config{}
@@ -198,10 +224,55 @@ func main() {
 	kingpin.Version(version.Print("flexlm_exporter"))
 	kingpin.CommandLine.UsageWriter(os.Stdout)
 	kingpin.HelpFlag.Short('h')
-	kingpin.Parse()
+	kingpin.Command("serve", "Serve the metrics over HTTP (default).").Default()
+	command := kingpin.Parse()
 
 	logger := promslog.New(promslogConfig)
 
+	if command == onceCmd.FullCommand() {
+		runtime.GOMAXPROCS(*maxProcs)
+
+		ok, err := runOnce(os.Stdout, *configPath, *onceFormat, logger)
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+
+		if !ok {
+			os.Exit(1)
+		}
+
+		return
+	}
+
+	if command == pushCmd.FullCommand() {
+		runtime.GOMAXPROCS(*maxProcs)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+
+		logger.Info("Starting flexlm_exporter push", "version", version.Info())
+
+		err := runPush(ctx, pushConfig{
+			pushgatewayURL: *pushgatewayURL,
+			remoteWriteURL: *remoteWriteURL,
+			job:            *pushJob,
+			interval:       *pushInterval,
+			timeout:        *pushTimeout,
+			retries:        *pushRetries,
+			retryBackoff:   *pushBackoff,
+			bufferSize:     *pushBufferSize,
+		}, *configPath, logger)
+
+		stop()
+
+		if err != nil {
+			logger.Error(err.Error())
+			os.Exit(1)
+		}
+
+		return
+	}
+
 	logger.Info("Starting flexlm_exporter", "version", version.Info())
 	logger.Info("Build context", "build_context", version.BuildContext())
 
@@ -213,12 +284,30 @@ func main() {
 	runtime.GOMAXPROCS(*maxProcs)
 	logger.Debug("Go MAXPROCS", "procs", runtime.GOMAXPROCS(0))
 	http.Handle(*metricsPath, newHandler(!*disableExporterMetrics, *configPath, *maxRequests, logger))
+	http.HandleFunc("/-/healthy", func(w http.ResponseWriter, _ *http.Request) {
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Healthy.\n"))
+	})
+	http.HandleFunc("/-/ready", func(w http.ResponseWriter, _ *http.Request) {
+		if err := collector.Ready(logger); err != nil {
+			logger.Warn("not ready", "err", err)
+			w.WriteHeader(http.StatusServiceUnavailable)
+			_, _ = fmt.Fprintf(w, "Not ready: %s\n", err)
+
+			return
+		}
+
+		w.WriteHeader(http.StatusOK)
+		_, _ = w.Write([]byte("Ready.\n"))
+	})
 	http.HandleFunc("/", func(w http.ResponseWriter, _ *http.Request) {
 		_, _ = w.Write([]byte(`<html>
 			<head><title>FLEXlm Exporter</title></head>
 			<body>
 			<h1>FLEXlm Exporter</h1>
 			<p><a href="` + *metricsPath + `">Metrics</a></p>
+			<p><a href="/-/healthy">Health</a></p>
+			<p><a href="/-/ready">Readiness</a></p>
 			</body>
 			</html>`))
 	})
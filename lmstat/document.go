@@ -0,0 +1,192 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+// Package lmstat parses the output of lmutil lmstat into a typed document.
+package lmstat
+
+import "fmt"
+
+// License types, as printed by lmstat.
+const (
+	LicenseTypeFloating            = "floating"
+	LicenseTypeNodeLocked          = "nodelocked"
+	LicenseTypeUncountedNodeLocked = "uncounted nodelocked"
+)
+
+// Section is a section of the lmstat output.
+type Section string
+
+// Sections of the lmstat output.
+const (
+	SectionHeader      Section = "header"
+	SectionServers     Section = "servers"
+	SectionVendors     Section = "vendors"
+	SectionFeatures    Section = "features"
+	SectionExpirations Section = "expirations"
+)
+
+// Document is the parsed output of lmstat -v, lmstat -a or lmstat -i.
+type Document struct {
+	Header      Header
+	Servers     []*Server
+	Vendors     []*Vendor
+	Features    []*Feature
+	Expirations []*Expiration
+	// Diagnostics are the lines that were not recognized.
+	Diagnostics []Diagnostic
+}
+
+// Header is the lmstat version and status date.
+type Header struct {
+	Version string
+	Build   string
+	Arch    string
+	// Date is the date of the status, e.g. "Thu 11/23/2017 15:08".
+	Date string
+}
+
+// Server is a license server of the "License server status" line, with the
+// status reported for it.
+type Server struct {
+	Name    string
+	Port    string
+	Up      bool
+	Master  bool
+	Version string
+	// Error is the error reported when lmstat can't connect to the server.
+	Error string
+}
+
+// Vendor is a vendor daemon status.
+type Vendor struct {
+	Name    string
+	Up      bool
+	Version string
+	// Error is the error reported instead of the status, e.g. "The desired
+	// vendor daemon is down.".
+	Error string
+}
+
+// Feature is a "Users of" block.
+type Feature struct {
+	Name   string
+	Issued int
+	Used   int
+	// Uncounted is set for uncounted, node-locked features.
+	Uncounted bool
+	// LicenseType is the last license type of the feature.
+	LicenseType string
+	// Error is the error reported instead of the usage, e.g.
+	// "1 licenses, unsupported by licensed server".
+	Error        string
+	Pools        []*Pool
+	Sessions     []*Session
+	Queues       []*Queue
+	Reservations []*Reservation
+}
+
+// Pool is a license pool of a feature, i.e. an INCREMENT line, e.g.
+// "MATLAB" v53, vendor: MLM, expiry: 01-jan-2099.
+type Pool struct {
+	Name         string
+	Version      string
+	Vendor       string
+	Expiry       string
+	LicenseType  string
+	VendorString string
+}
+
+// Session is a checkout.
+type Session struct {
+	User    string
+	Host    string
+	Display string
+	Version string
+	Server  string
+	Handle  string
+	// Start is the start time as printed by lmstat, without year, e.g.
+	// "Fri 10/20 16:44".
+	Start       string
+	Licenses    int
+	LicenseType string
+	// Pool is the pool the session is listed in, if any.
+	Pool *Pool
+}
+
+// Queue is a queued license request.
+type Queue struct {
+	User        string
+	Host        string
+	Display     string
+	Version     string
+	Licenses    int
+	LicenseType string
+	Pool        *Pool
+}
+
+// Reservation is a reservation not in use, e.g.
+// 2 RESERVATIONs for HOST node-01.cluster.
+type Reservation struct {
+	Count int
+	// Type is USER, HOST, DISPLAY, INTERNET, PROJECT, GROUP or HOST_GROUP.
+	Type        string
+	Target      string
+	LicenseType string
+	Pool        *Pool
+}
+
+// Expiration is a line of lmstat -i.
+type Expiration struct {
+	Feature  string
+	Version  string
+	Licenses int
+	Expires  string
+	Vendor   string
+}
+
+// Diagnostic is a line that was not recognized, with its line number and
+// the section it was found in.
+type Diagnostic struct {
+	Line    int
+	Section Section
+	Text    string
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("line %d: unrecognized lmstat output in %s section %q", d.Line, d.Section, d.Text)
+}
+
+// Unparsed returns the number of lines that were not recognized in a
+// section.
+func (d *Document) Unparsed(section Section) int {
+	unparsed := 0
+
+	for _, diagnostic := range d.Diagnostics {
+		if diagnostic.Section == section {
+			unparsed++
+		}
+	}
+
+	return unparsed
+}
+
+// Feature returns the feature with the given name, or nil.
+func (d *Document) Feature(name string) *Feature {
+	for _, f := range d.Features {
+		if f.Name == name {
+			return f
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,388 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lmstat
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+const maxLineLength = 1024 * 1024
+
+var (
+	versionRegex = regexp.MustCompile(
+		`^lmstat (?P<version>v[\d\.]+) build (?P<build>\d+) (?P<arch>[\w]+)`)
+	dateRegex = regexp.MustCompile(
+		`^Flexible License Manager status on (?P<date>.+?)\s*$`)
+	serversRegex = regexp.MustCompile(
+		`^License server status: (?P<servers>[\w,.@\-]+)`)
+	serverStatusRegex = regexp.MustCompile(
+		`^\s*(?P<name>[\w\.\-]+): license server (?P<status>\w+)(?P<master>\s\(MASTER\))? (?P<version>v[\d\.]+)$`)
+	serverErrorRegex = regexp.MustCompile(
+		`^\s*(?P<name>[\w\.\-]+): (?P<error>Cannot .+?)\s*$`)
+	vendorSectionRegex = regexp.MustCompile(
+		`^Vendor daemon status \(on (?P<host>.+)\):\s*$`)
+	vendorStatusRegex = regexp.MustCompile(
+		`^\s+(?P<name>\w+): (?P<status>UP|DOWN) (?P<version>v[\d\.]+)$`)
+	vendorErrorRegex = regexp.MustCompile(
+		`^\s+(?P<name>\w+): (?P<error>.+?)\s*$`)
+	usageRegex = regexp.MustCompile(
+		`^Users of (?P<name>.*):\s+\(Total of (?P<issued>\d+) \w+ issued\;\s+` +
+			`Total of (?P<used>\d+) \w+ in use\)$`)
+	usageNodeLockedRegex = regexp.MustCompile(
+		`^Users of (?P<name>.*):\s+\(Uncounted, node-locked\)$`)
+	usageErrorRegex = regexp.MustCompile(
+		`^Users of (?P<name>.*):\s+\(Error: (?P<error>.*)\)\s*$`)
+	licenseTypeRegex = regexp.MustCompile(
+		`^\s+(?P<type>floating|uncounted nodelocked|nodelocked) license`)
+	poolRegex = regexp.MustCompile(
+		`^\s*"(?P<name>[^"]+)" v(?P<version>[\w\.\-]+), vendor: (?P<vendor>[\w\-]+)` +
+			`(, expiry: (?P<expiry>[\w\-]+))?`)
+	vendorStringRegex = regexp.MustCompile(
+		`^\s+vendor_string: (?P<vendor_string>.*?)\s*$`)
+	sessionRegex = regexp.MustCompile(
+		`^\s+(?P<user>[\w[:print:]]+) (?P<host>[\w\-\.]+) (?P<display>[[:print:]]+) \(v(?P<version>[\w\.]+)\) ` +
+			`\((?P<server>[\w\-\.]+)\/\d+ (?P<handle>\d+)\)\, start (?P<start>\w+ \d+\/\d+ \d+\:\d+)` +
+			`(\,\s(?P<licenses>\d+)\s\w+|)(\s+\(linger\:\s\d+\s\/\s\d+\))?(\,\s+PID\:\s+\d+\s?)?$`)
+	// Sessions without display.
+	session2Regex = regexp.MustCompile(
+		`^\s+(?P<user>[\w[:print:]]+) (?P<host>[\w\-\.]+) \(v(?P<version>[\w\.]+)\) ` +
+			`\((?P<server>[\w\-\.]+)\/\d+ (?P<handle>\d+)\)\, start (?P<start>\w+ \d+\/\d+ \d+\:\d+)` +
+			`(\,\s(?P<licenses>\d+)\s\w+|)(\s+\(linger\:\s\d+\s\/\s\d+\))?$`)
+	queueRegex = regexp.MustCompile(
+		`^\s+(?P<user>[\w[:print:]]+) (?P<host>[\w\-\.]+) (?P<display>[[:print:]]+) [0-9.]+ \(v(?P<version>[\w\.]+)\) ` +
+			`\((?P<server>[\w\-\.]+)\/\d+ \d+\)\s+queued for (?P<licenses>\d+) license[s]?$`)
+	reservationRegex = regexp.MustCompile(
+		`^\s*(?P<count>\d+)\s+\w+\s+for\s+` +
+			`(?P<type>USER|HOST_GROUP|HOST|DISPLAY|INTERNET|PROJECT|GROUP)\s+(?P<target>[^\s]+)` +
+			`(\s+\((?P<server>[\w\-\.]+)\/\d+\))?`)
+	// lmstat -i.
+	expirationRegex = regexp.MustCompile(
+		`^(?P<feature>[[:graph:]]+)\s+(?P<version>[\d\.]+)\s+` +
+			`(?P<licenses>\d+)\s+(?P<expires>[\w\-]+)\s+(?P<vendor>\w+)$`)
+	// lmstat -i case with columns expired and vendors switched #28.
+	expiration2Regex = regexp.MustCompile(
+		`^(?P<feature>[[:graph:]]+)\s+(?P<version>[\d\.]+)\s+` +
+			`(?P<licenses>\d+)\s+(?P<vendor>\w+)\s+(?P<expires>[\w\-\s\(\)]+)$`)
+	expirationHeaderRegex = regexp.MustCompile(
+		`^Feature\s+Version\s+#licenses\s+(Expires\s+Vendor|Vendor\s+Expires)\s*$`)
+	// Lines without information.
+	ignoredRegexes = []*regexp.Regexp{
+		regexp.MustCompile(`^lmutil - Copyright`),
+		regexp.MustCompile(`^lmstat\s*$`),
+		regexp.MustCompile(`^\[Detecting lmgrd processes\.\.\.\]\s*$`),
+		regexp.MustCompile(`^\s*License file\(s\) on (?P<host>[^:]+):`),
+		regexp.MustCompile(`^Feature usage info:\s*$`),
+		regexp.MustCompile(`^NOTE: lmstat -i does not give information from the server,\s*$`),
+		regexp.MustCompile(`^\s+but only reads the license file\.`),
+		regexp.MustCompile(`^\s+lmstat -a is recommended instead\.\s*$`),
+		regexp.MustCompile(`^_+(\s+_+)*\s*$`),
+	}
+)
+
+// parser keeps the state of the parsing.
+type parser struct {
+	doc         *Document
+	section     Section
+	feature     *Feature
+	pool        *Pool
+	licenseType string
+	// redacting enables the positions of the usernames and hostnames of the
+	// current line in spans.
+	redacting bool
+	spans     []span
+}
+
+// Parse parses the output of lmstat -v, lmstat -a or lmstat -i. The lines
+// that are not recognized are returned as diagnostics.
+func Parse(r io.Reader) (*Document, error) {
+	p := &parser{doc: &Document{}, section: SectionHeader}
+
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(nil, maxLineLength)
+	scanner.Split(bufio.ScanLines)
+
+	for number := 1; scanner.Scan(); number++ {
+		line := strings.TrimSuffix(scanner.Text(), "\r")
+		if strings.TrimSpace(line) == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+
+		if !p.parseLine(line) {
+			p.doc.Diagnostics = append(p.doc.Diagnostics, Diagnostic{Line: number, Section: p.section, Text: line})
+		}
+	}
+
+	if err := scanner.Err(); err != nil {
+		return p.doc, fmt.Errorf("could not parse lmstat output: %w", err)
+	}
+
+	return p.doc, nil
+}
+
+// parseLine parses a line, and returns whether it was recognized.
+func (p *parser) parseLine(line string) bool {
+	for _, r := range ignoredRegexes {
+		if r.MatchString(line) {
+			p.mark(r, line, hostFields)
+			return true
+		}
+	}
+
+	switch {
+	case versionRegex.MatchString(line):
+		m := subMatchMap(versionRegex, line)
+		p.doc.Header.Version, p.doc.Header.Build, p.doc.Header.Arch = m["version"], m["build"], m["arch"]
+	case dateRegex.MatchString(line):
+		p.doc.Header.Date = subMatchMap(dateRegex, line)["date"]
+	case serversRegex.MatchString(line):
+		p.section = SectionServers
+		p.markServers(line)
+		p.parseServers(subMatchMap(serversRegex, line)["servers"])
+	case vendorSectionRegex.MatchString(line):
+		p.section = SectionVendors
+		p.mark(vendorSectionRegex, line, hostFields)
+	case expirationHeaderRegex.MatchString(line):
+		p.section = SectionExpirations
+	default:
+		return p.parseSectionLine(line)
+	}
+
+	return true
+}
+
+func (p *parser) parseSectionLine(line string) bool {
+	if p.parseFeatureHeader(line) {
+		return true
+	}
+
+	switch {
+	case p.section == SectionServers && serverStatusRegex.MatchString(line):
+		p.mark(serverStatusRegex, line, serverFields)
+		m := subMatchMap(serverStatusRegex, line)
+		for _, s := range p.server(m["name"]) {
+			// A server listed twice is up, or master, if any of its status
+			// lines says so.
+			s.Up = s.Up || m["status"] == "UP"
+			s.Master = s.Master || m["master"] != ""
+			s.Version = m["version"]
+		}
+	case p.section == SectionServers && serverErrorRegex.MatchString(line):
+		p.mark(serverErrorRegex, line, serverFields)
+		m := subMatchMap(serverErrorRegex, line)
+		for _, s := range p.server(m["name"]) {
+			s.Error = m["error"]
+		}
+	case vendorStatusRegex.MatchString(line):
+		m := subMatchMap(vendorStatusRegex, line)
+		p.doc.Vendors = append(p.doc.Vendors, &Vendor{Name: m["name"], Up: m["status"] == "UP", Version: m["version"]})
+	case p.section == SectionVendors && vendorErrorRegex.MatchString(line):
+		m := subMatchMap(vendorErrorRegex, line)
+		p.doc.Vendors = append(p.doc.Vendors, &Vendor{Name: m["name"], Error: m["error"]})
+	case p.section == SectionFeatures:
+		return p.parseFeatureLine(line)
+	case expirationRegex.MatchString(line):
+		p.parseExpiration(subMatchMap(expirationRegex, line))
+	case expiration2Regex.MatchString(line):
+		p.parseExpiration(subMatchMap(expiration2Regex, line))
+	default:
+		return false
+	}
+
+	return true
+}
+
+// parseFeatureHeader parses the "Users of" lines.
+func (p *parser) parseFeatureHeader(line string) bool {
+	var f *Feature
+
+	switch {
+	case usageRegex.MatchString(line):
+		m := subMatchMap(usageRegex, line)
+		f = &Feature{Name: m["name"], Issued: atoi(m["issued"]), Used: atoi(m["used"]), LicenseType: LicenseTypeFloating}
+	case usageNodeLockedRegex.MatchString(line):
+		f = &Feature{
+			Name:        subMatchMap(usageNodeLockedRegex, line)["name"],
+			Uncounted:   true,
+			LicenseType: LicenseTypeUncountedNodeLocked,
+		}
+	case usageErrorRegex.MatchString(line):
+		m := subMatchMap(usageErrorRegex, line)
+		f = &Feature{Name: m["name"], Error: m["error"], LicenseType: LicenseTypeFloating}
+	default:
+		return false
+	}
+
+	p.section = SectionFeatures
+	p.feature = f
+	p.pool = nil
+	p.licenseType = f.LicenseType
+	p.doc.Features = append(p.doc.Features, f)
+
+	return true
+}
+
+// parseFeatureLine parses the pools, license types, sessions, queues and
+// reservations of a feature.
+func (p *parser) parseFeatureLine(line string) bool {
+	f := p.feature
+
+	switch {
+	case licenseTypeRegex.MatchString(line):
+		p.licenseType = subMatchMap(licenseTypeRegex, line)["type"]
+		f.LicenseType = p.licenseType
+
+		if p.pool != nil {
+			p.pool.LicenseType = p.licenseType
+		}
+	case poolRegex.MatchString(line):
+		m := subMatchMap(poolRegex, line)
+		p.pool = &Pool{Name: m["name"], Version: m["version"], Vendor: m["vendor"], Expiry: m["expiry"]}
+		f.Pools = append(f.Pools, p.pool)
+	case vendorStringRegex.MatchString(line):
+		if p.pool != nil {
+			p.pool.VendorString = subMatchMap(vendorStringRegex, line)["vendor_string"]
+		}
+	case sessionRegex.MatchString(line) && strings.TrimSpace(subMatchMap(sessionRegex, line)["user"]) != "":
+		p.mark(sessionRegex, line, sessionFields)
+		f.Sessions = append(f.Sessions, p.session(subMatchMap(sessionRegex, line)))
+	case session2Regex.MatchString(line):
+		// Sessions without display match the first regexp with a blank user.
+		p.mark(session2Regex, line, sessionFields)
+		f.Sessions = append(f.Sessions, p.session(subMatchMap(session2Regex, line)))
+	case queueRegex.MatchString(line):
+		p.mark(queueRegex, line, sessionFields)
+		m := subMatchMap(queueRegex, line)
+		f.Queues = append(f.Queues, &Queue{
+			User:        m["user"],
+			Host:        m["host"],
+			Display:     m["display"],
+			Version:     m["version"],
+			Licenses:    atoi(m["licenses"]),
+			LicenseType: p.licenseType,
+			Pool:        p.pool,
+		})
+	case reservationRegex.MatchString(line):
+		m := subMatchMap(reservationRegex, line)
+		p.mark(reservationRegex, line, reservationFields[m["type"]])
+		p.mark(reservationRegex, line, serverFields)
+		f.Reservations = append(f.Reservations, &Reservation{
+			Count:       atoi(m["count"]),
+			Type:        m["type"],
+			Target:      m["target"],
+			LicenseType: p.licenseType,
+			Pool:        p.pool,
+		})
+	default:
+		return false
+	}
+
+	return true
+}
+
+func (p *parser) session(m map[string]string) *Session {
+	s := &Session{
+		User:        m["user"],
+		Host:        m["host"],
+		Display:     m["display"],
+		Version:     m["version"],
+		Server:      m["server"],
+		Handle:      m["handle"],
+		Start:       m["start"],
+		Licenses:    1,
+		LicenseType: p.licenseType,
+		Pool:        p.pool,
+	}
+
+	if m["licenses"] != "" {
+		s.Licenses = atoi(m["licenses"])
+	}
+
+	return s
+}
+
+// parseServers parses a port@host list.
+func (p *parser) parseServers(servers string) {
+	for portServer := range strings.SplitSeq(servers, ",") {
+		port, name, ok := strings.Cut(portServer, "@")
+		if !ok {
+			continue
+		}
+
+		p.doc.Servers = append(p.doc.Servers, &Server{Name: name, Port: port})
+	}
+}
+
+// server returns the servers with the same host name, regardless of the case
+// and the domain. A server that is not listed is added.
+func (p *parser) server(name string) []*Server {
+	short := shortName(name)
+
+	var servers []*Server
+
+	for _, s := range p.doc.Servers {
+		if shortName(s.Name) == short {
+			servers = append(servers, s)
+		}
+	}
+
+	if len(servers) == 0 {
+		s := &Server{Name: name}
+		p.doc.Servers = append(p.doc.Servers, s)
+		servers = append(servers, s)
+	}
+
+	return servers
+}
+
+func (p *parser) parseExpiration(m map[string]string) {
+	p.doc.Expirations = append(p.doc.Expirations, &Expiration{
+		Feature:  m["feature"],
+		Version:  m["version"],
+		Licenses: atoi(m["licenses"]),
+		Expires:  m["expires"],
+		Vendor:   m["vendor"],
+	})
+}
+
+func shortName(name string) string {
+	return strings.ToLower(strings.Split(name, ".")[0])
+}
+
+// atoi converts the digits matched by a regexp.
+func atoi(s string) int {
+	n, err := strconv.Atoi(s)
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+func subMatchMap(r *regexp.Regexp, str string) map[string]string {
+	match := r.FindStringSubmatch(str)
+	subMatchMap := make(map[string]string)
+
+	for i, name := range r.SubexpNames() {
+		if i != 0 && name != "" {
+			subMatchMap[name] = match[i]
+		}
+	}
+
+	return subMatchMap
+}
@@ -0,0 +1,155 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lmstat_test
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mjtrangoni/flexlm_exporter/lmstat"
+)
+
+const (
+	testLmstatA = "fixtures/lmstat_a.txt"
+)
+
+func TestParse(t *testing.T) {
+	t.Parallel()
+
+	file, err := os.Open(testLmstatA)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer file.Close()
+
+	doc, err := lmstat.Parse(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if doc.Header.Date != "Mon 3/16/2026 12:16" {
+		t.Fatalf("Unexpected date: %q", doc.Header.Date)
+	}
+
+	if len(doc.Servers) != 3 {
+		t.Fatalf("Unexpected number of servers: %d != 3", len(doc.Servers))
+	}
+
+	if s := doc.Servers[0]; s.Name != "host1.domain.net" || s.Port != "27000" || !s.Up || s.Master || s.Version != "v11.19.7" {
+		t.Fatalf("Unexpected server: %+v", s)
+	}
+
+	if s := doc.Servers[1]; !s.Up || !s.Master {
+		t.Fatalf("Unexpected master server: %+v", s)
+	}
+
+	if s := doc.Servers[2]; s.Up || !strings.HasPrefix(s.Error, "Cannot connect to license server system.") {
+		t.Fatalf("Unexpected server down: %+v", s)
+	}
+
+	if len(doc.Vendors) != 2 || !doc.Vendors[0].Up || doc.Vendors[1].Up ||
+		doc.Vendors[1].Error != "The desired vendor daemon is down. 3/16 12:10" {
+		t.Fatalf("Unexpected vendors: %+v, %+v", doc.Vendors[0], doc.Vendors[1])
+	}
+
+	f := doc.Feature("feature1")
+	if f == nil || f.Issued != 20 || f.Used != 6 || f.LicenseType != lmstat.LicenseTypeFloating {
+		t.Fatalf("Unexpected feature1: %+v", f)
+	}
+
+	if len(f.Pools) != 1 || f.Pools[0].Expiry != "01-jan-2099" || f.Pools[0].VendorString != "site=HQ" {
+		t.Fatalf("Unexpected pools of feature1: %+v", f.Pools)
+	}
+
+	expected := []lmstat.Session{
+		{User: "user1", Host: "host4", Display: "/dev/pts/1", Handle: "101", Licenses: 1},
+		// Usernames may contain spaces, and displays pipes.
+		{User: "Jane Doe", Host: "host5", Display: "pts|0", Handle: "102", Licenses: 2},
+		{User: "user3", Host: "host6", Handle: "103", Licenses: 1},
+	}
+
+	if len(f.Sessions) != len(expected) {
+		t.Fatalf("Unexpected number of sessions: %d != %d", len(f.Sessions), len(expected))
+	}
+
+	for i, s := range f.Sessions {
+		if s.User != expected[i].User || s.Host != expected[i].Host || s.Display != expected[i].Display ||
+			s.Handle != expected[i].Handle || s.Licenses != expected[i].Licenses || s.Version != "2.0" ||
+			s.Start == "" || s.Pool != f.Pools[0] {
+			t.Fatalf("Unexpected session: %+v != %+v", *s, expected[i])
+		}
+	}
+
+	if len(f.Queues) != 1 || f.Queues[0].User != "user4" || f.Queues[0].Licenses != 3 {
+		t.Fatalf("Unexpected queues: %+v", f.Queues)
+	}
+
+	if len(f.Reservations) != 1 || f.Reservations[0].Type != "HOST" || f.Reservations[0].Target != "node-01.cluster" ||
+		f.Reservations[0].Count != 2 {
+		t.Fatalf("Unexpected reservations: %+v", f.Reservations)
+	}
+
+	if f = doc.Feature("feature2"); f == nil || f.Error != "1 licenses, unsupported by licensed server" {
+		t.Fatalf("Unexpected feature2: %+v", f)
+	}
+
+	f = doc.Feature("feature3")
+	if f == nil || !f.Uncounted || f.LicenseType != lmstat.LicenseTypeNodeLocked || len(f.Pools) != 1 ||
+		f.Pools[0].Expiry != "permanent" {
+		t.Fatalf("Unexpected feature3: %+v", f)
+	}
+
+	if len(doc.Diagnostics) != 1 || doc.Diagnostics[0].Line != 37 || doc.Diagnostics[0].Section != lmstat.SectionFeatures ||
+		doc.Diagnostics[0].Text != "this line is not lmstat output" {
+		t.Fatalf("Unexpected diagnostics: %v", doc.Diagnostics)
+	}
+
+	if doc.Unparsed(lmstat.SectionFeatures) != 1 || doc.Unparsed(lmstat.SectionServers) != 0 {
+		t.Fatalf("Unexpected number of unparsed lines: %d, %d != 1, 0",
+			doc.Unparsed(lmstat.SectionFeatures), doc.Unparsed(lmstat.SectionServers))
+	}
+}
+
+func TestParseExpirations(t *testing.T) {
+	t.Parallel()
+
+	out := `NOTE: lmstat -i does not give information from the server,
+      but only reads the license file.  For this reason,
+      lmstat -a is recommended instead.
+
+Feature                         Version     #licenses    Vendor        Expires
+_______                         _________   _________    ______        ________
+feature1                        2018.12      2           vendor1       31-dec-2018
+feature2                        0.1          1           vendor2       1-jan-0
+`
+
+	doc, err := lmstat.Parse(strings.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(doc.Diagnostics) != 0 {
+		t.Fatalf("Unexpected diagnostics: %v", doc.Diagnostics)
+	}
+
+	if len(doc.Expirations) != 2 {
+		t.Fatalf("Unexpected number of expirations: %d != 2", len(doc.Expirations))
+	}
+
+	if e := doc.Expirations[0]; e.Feature != "feature1" || e.Version != "2018.12" || e.Licenses != 2 ||
+		e.Vendor != "vendor1" || e.Expires != "31-dec-2018" {
+		t.Fatalf("Unexpected expiration: %+v", e)
+	}
+}
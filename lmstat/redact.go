@@ -0,0 +1,132 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lmstat
+
+import (
+	"bytes"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// Field is the kind of a value replaced by Redact.
+type Field string
+
+// Fields replaced by Redact.
+const (
+	FieldUser Field = "user"
+	FieldHost Field = "host"
+)
+
+var (
+	hostFields    = map[string]Field{"host": FieldHost}
+	serverFields  = map[string]Field{"name": FieldHost, "server": FieldHost}
+	sessionFields = map[string]Field{
+		"user": FieldUser, "host": FieldHost, "display": FieldHost, "server": FieldHost,
+	}
+	reservationFields = map[string]map[string]Field{
+		"USER":     {"target": FieldUser},
+		"HOST":     {"target": FieldHost},
+		"DISPLAY":  {"target": FieldHost},
+		"INTERNET": {"target": FieldHost},
+	}
+)
+
+// span is the position of a username or hostname in a line.
+type span struct {
+	field      Field
+	start, end int
+}
+
+// Redact returns the lmstat output with the usernames and hostnames replaced
+// by replace. They are only replaced where the parser reads them, i.e. in the
+// license servers, the vendor daemon and license file hosts, the sessions, the
+// queues and the USER, HOST, DISPLAY and INTERNET reservations, so that the
+// other values, e.g. a feature named like a user, are kept.
+func Redact(out []byte, replace func(field Field, value string) string) []byte {
+	p := &parser{doc: &Document{}, section: SectionHeader, redacting: true}
+
+	var redacted bytes.Buffer
+
+	for _, raw := range bytes.SplitAfter(out, []byte("\n")) {
+		line := string(raw)
+		ending := line[len(strings.TrimRight(line, "\r\n")):]
+		line = strings.TrimSuffix(line, ending)
+
+		p.spans = p.spans[:0]
+		if strings.TrimSpace(line) != "" && !strings.HasPrefix(line, "#") {
+			p.parseLine(line)
+		}
+
+		sort.Slice(p.spans, func(i, j int) bool { return p.spans[i].start < p.spans[j].start })
+
+		last := 0
+
+		for _, s := range p.spans {
+			if s.start < last {
+				continue
+			}
+
+			redacted.WriteString(line[last:s.start])
+			redacted.WriteString(replace(s.field, line[s.start:s.end]))
+			last = s.end
+		}
+
+		redacted.WriteString(line[last:])
+		redacted.WriteString(ending)
+	}
+
+	return redacted.Bytes()
+}
+
+// mark records the positions of the named groups of r in line that are
+// fields, when redacting.
+func (p *parser) mark(r *regexp.Regexp, line string, fields map[string]Field) {
+	if !p.redacting || len(fields) == 0 {
+		return
+	}
+
+	match := r.FindStringSubmatchIndex(line)
+	if match == nil {
+		return
+	}
+
+	for i, name := range r.SubexpNames() {
+		field, ok := fields[name]
+		if !ok || match[2*i] < 0 || match[2*i] == match[2*i+1] {
+			continue
+		}
+
+		p.spans = append(p.spans, span{field: field, start: match[2*i], end: match[2*i+1]})
+	}
+}
+
+// markServers records the positions of the hosts of a port@host list.
+func (p *parser) markServers(line string) {
+	if !p.redacting {
+		return
+	}
+
+	match := serversRegex.FindStringSubmatchIndex(line)
+	group := serversRegex.SubexpIndex("servers")
+	start := match[2*group]
+
+	for portServer := range strings.SplitSeq(line[start:match[2*group+1]], ",") {
+		if at := strings.Index(portServer, "@"); at >= 0 && at+1 < len(portServer) {
+			p.spans = append(p.spans, span{field: FieldHost, start: start + at + 1, end: start + len(portServer)})
+		}
+
+		start += len(portServer) + 1
+	}
+}
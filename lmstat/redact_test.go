@@ -0,0 +1,68 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package lmstat_test
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mjtrangoni/flexlm_exporter/lmstat"
+)
+
+func TestRedact(t *testing.T) {
+	t.Parallel()
+
+	out, err := os.ReadFile(testLmstatA)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// Replacing the values with themselves keeps the output.
+	if !bytes.Equal(lmstat.Redact(out, func(_ lmstat.Field, value string) string { return value }), out) {
+		t.Fatal("identity redaction changed the output")
+	}
+
+	redacted := lmstat.Redact(out, func(field lmstat.Field, value string) string {
+		return strings.ToUpper(string(field)) + "_" + strings.ReplaceAll(value, " ", "_")
+	})
+
+	before, err := lmstat.Parse(bytes.NewReader(out))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	after, err := lmstat.Parse(bytes.NewReader(redacted))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(after.Diagnostics) != len(before.Diagnostics) || len(after.Features) != len(before.Features) {
+		t.Fatalf("redacted output doesn't parse the same")
+	}
+
+	for i, f := range before.Features {
+		if after.Features[i].Name != f.Name || len(after.Features[i].Sessions) != len(f.Sessions) {
+			t.Fatalf("%s: redacted feature doesn't match", f.Name)
+		}
+
+		for j, s := range f.Sessions {
+			if got := after.Features[i].Sessions[j]; got.User != "USER_"+strings.ReplaceAll(s.User, " ", "_") ||
+				got.Host != "HOST_"+s.Host {
+				t.Fatalf("%s: want %s@%s redacted, got %s@%s", f.Name, s.User, s.Host, got.User, got.Host)
+			}
+		}
+	}
+}
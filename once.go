@@ -0,0 +1,209 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/mjtrangoni/flexlm_exporter/collector"
+	"github.com/mjtrangoni/flexlm_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	promcollectorsversion "github.com/prometheus/client_golang/prometheus/collectors/version"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+)
+
+// Output formats of the once command.
+const (
+	formatText        = "text"
+	formatOpenMetrics = "openmetrics"
+	formatJSON        = "json"
+)
+
+// jsonFamily is a metric family of the JSON output.
+type jsonFamily struct {
+	Name    string       `json:"name"`
+	Help    string       `json:"help"`
+	Type    string       `json:"type"`
+	Metrics []jsonMetric `json:"metrics"`
+}
+
+// jsonMetric is a metric of the JSON output. The values are strings, as in the
+// Prometheus HTTP API, since JSON has no infinite numbers.
+type jsonMetric struct {
+	Labels    map[string]string `json:"labels,omitempty"`
+	Value     string            `json:"value,omitempty"`
+	Count     string            `json:"count,omitempty"`
+	Sum       string            `json:"sum,omitempty"`
+	Buckets   map[string]string `json:"buckets,omitempty"`
+	Quantiles map[string]string `json:"quantiles,omitempty"`
+}
+
+// runOnce runs the enabled collectors a single time and writes the metrics to
+// w. It returns whether all the collectors and licenses were scraped
+// successfully.
+func runOnce(w io.Writer, configPath, format string, logger *slog.Logger) (bool, error) {
+	nc, err := collector.NewFlexlmCollector(logger)
+	if err != nil {
+		return false, fmt.Errorf("couldn't create collector: %w", err)
+	}
+
+	collector.LicenseConfig, err = config.Load(configPath, logger)
+	if err != nil {
+		return false, fmt.Errorf("couldn't load config file %s: %w", configPath, err)
+	}
+
+	r := prometheus.NewRegistry()
+	r.MustRegister(promcollectorsversion.NewCollector("flexlm_exporter"))
+
+	if err := r.Register(nc); err != nil {
+		return false, fmt.Errorf("couldn't register node collector: %w", err)
+	}
+
+	return writeOnce(w, r, format)
+}
+
+// writeOnce gathers the metrics of g, writes them to w and returns whether all
+// the collectors and licenses were scraped successfully.
+func writeOnce(w io.Writer, g prometheus.Gatherer, format string) (bool, error) {
+	mfs, err := g.Gather()
+	if err != nil {
+		return false, fmt.Errorf("couldn't gather metrics: %w", err)
+	}
+
+	if err := writeMetricFamilies(w, mfs, format); err != nil {
+		return false, err
+	}
+
+	return scrapeSucceeded(mfs), nil
+}
+
+// scrapeSucceeded returns false if a collector failed or a license had a
+// scrape error, including a scrape skipped after consecutive failures.
+func scrapeSucceeded(mfs []*dto.MetricFamily) bool {
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			switch mf.GetName() {
+			case "flexlm_scrape_collector_success":
+				if m.GetGauge().GetValue() == 0 {
+					return false
+				}
+			case "flexlm_scrape_error":
+				if m.GetGauge().GetValue() != 0 {
+					return false
+				}
+			}
+		}
+	}
+
+	return true
+}
+
+func writeMetricFamilies(w io.Writer, mfs []*dto.MetricFamily, format string) error {
+	switch format {
+	case formatText, formatOpenMetrics:
+		f := expfmt.NewFormat(expfmt.TypeTextPlain)
+		if format == formatOpenMetrics {
+			f = expfmt.NewFormat(expfmt.TypeOpenMetrics)
+		}
+
+		enc := expfmt.NewEncoder(w, f)
+		for _, mf := range mfs {
+			if err := enc.Encode(mf); err != nil {
+				return fmt.Errorf("couldn't encode %s: %w", mf.GetName(), err)
+			}
+		}
+
+		if closer, ok := enc.(expfmt.Closer); ok {
+			if err := closer.Close(); err != nil {
+				return fmt.Errorf("couldn't finalize output: %w", err)
+			}
+		}
+
+		return nil
+	case formatJSON:
+		enc := json.NewEncoder(w)
+		enc.SetIndent("", "  ")
+
+		if err := enc.Encode(jsonFamilies(mfs)); err != nil {
+			return fmt.Errorf("couldn't encode metrics: %w", err)
+		}
+
+		return nil
+	default:
+		return fmt.Errorf("unknown output format: %s", format)
+	}
+}
+
+func formatValue(v float64) string {
+	return strconv.FormatFloat(v, 'g', -1, 64)
+}
+
+func jsonFamilies(mfs []*dto.MetricFamily) []jsonFamily {
+	families := make([]jsonFamily, 0, len(mfs))
+
+	for _, mf := range mfs {
+		family := jsonFamily{
+			Name:    mf.GetName(),
+			Help:    mf.GetHelp(),
+			Type:    strings.ToLower(mf.GetType().String()),
+			Metrics: make([]jsonMetric, 0, len(mf.GetMetric())),
+		}
+
+		for _, m := range mf.GetMetric() {
+			metric := jsonMetric{Labels: make(map[string]string, len(m.GetLabel()))}
+			for _, l := range m.GetLabel() {
+				metric.Labels[l.GetName()] = l.GetValue()
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				metric.Value = formatValue(m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				metric.Value = formatValue(m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				metric.Value = formatValue(m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				metric.Count = strconv.FormatUint(h.GetSampleCount(), 10)
+				metric.Sum = formatValue(h.GetSampleSum())
+				metric.Buckets = make(map[string]string, len(h.GetBucket()))
+
+				for _, b := range h.GetBucket() {
+					metric.Buckets[formatValue(b.GetUpperBound())] = strconv.FormatUint(b.GetCumulativeCount(), 10)
+				}
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				metric.Count = strconv.FormatUint(s.GetSampleCount(), 10)
+				metric.Sum = formatValue(s.GetSampleSum())
+				metric.Quantiles = make(map[string]string, len(s.GetQuantile()))
+
+				for _, q := range s.GetQuantile() {
+					metric.Quantiles[formatValue(q.GetQuantile())] = formatValue(q.GetValue())
+				}
+			}
+
+			family.Metrics = append(family.Metrics, metric)
+		}
+
+		families = append(families, family)
+	}
+
+	return families
+}
@@ -0,0 +1,134 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// onceRegistry returns a registry with the scrape metrics of a collector and
+// a license, and a feature usage.
+func onceRegistry(collectorSuccess, licenseError float64, reason string) *prometheus.Registry {
+	success := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "flexlm_scrape_collector_success",
+		Help: "flexlm_exporter: Whether a collector succeeded.",
+	}, []string{"collector"})
+	success.WithLabelValues("lmstat").Set(collectorSuccess)
+
+	scrapeError := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "flexlm_scrape_error",
+		Help: "flexlm_exporter: Whether an error occurred.",
+	}, []string{"collector", "name", "reason"})
+	scrapeError.WithLabelValues("lmstat", "app1", reason).Set(licenseError)
+
+	used := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "flexlm_feature_used",
+		Help: "License feature used.",
+	}, []string{"app", "name"})
+	used.WithLabelValues("app1", "feature1").Set(3)
+
+	duration := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "flexlm_lmutil_wait_seconds",
+		Help:    "flexlm_exporter: Time lmutil calls waited.",
+		Buckets: []float64{1},
+	})
+	duration.Observe(0.5)
+
+	r := prometheus.NewRegistry()
+	r.MustRegister(success, scrapeError, used, duration)
+
+	return r
+}
+
+func TestWriteOnce(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name             string
+		collectorSuccess float64
+		licenseError     float64
+		reason           string
+		want             bool
+	}{
+		{"success", 1, 0, "", true},
+		{"collector failure", 0, 0, "", false},
+		{"license error", 1, 1, "error", false},
+		{"license skipped after consecutive failures", 1, 1, "backoff", false},
+	} {
+		var out bytes.Buffer
+
+		ok, err := writeOnce(&out, onceRegistry(tc.collectorSuccess, tc.licenseError, tc.reason), formatText)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		if ok != tc.want {
+			t.Fatalf("%s: want %v, got %v", tc.name, tc.want, ok)
+		}
+
+		if !strings.Contains(out.String(), `flexlm_feature_used{app="app1",name="feature1"} 3`) {
+			t.Fatalf("%s: unexpected text output:\n%s", tc.name, out.String())
+		}
+	}
+}
+
+func TestWriteOnceFormats(t *testing.T) {
+	t.Parallel()
+
+	var out bytes.Buffer
+	if _, err := writeOnce(&out, onceRegistry(1, 0, ""), formatOpenMetrics); err != nil {
+		t.Fatal(err)
+	}
+
+	if !strings.HasSuffix(out.String(), "# EOF\n") {
+		t.Fatalf("unexpected OpenMetrics output:\n%s", out.String())
+	}
+
+	out.Reset()
+
+	if _, err := writeOnce(&out, onceRegistry(1, 0, ""), formatJSON); err != nil {
+		t.Fatal(err)
+	}
+
+	var families []jsonFamily
+	if err := json.Unmarshal(out.Bytes(), &families); err != nil {
+		t.Fatal(err)
+	}
+
+	byName := make(map[string]jsonFamily, len(families))
+	for _, f := range families {
+		byName[f.Name] = f
+	}
+
+	used := byName["flexlm_feature_used"]
+	if used.Type != "gauge" || len(used.Metrics) != 1 || used.Metrics[0].Value != "3" ||
+		used.Metrics[0].Labels["app"] != "app1" || used.Metrics[0].Labels["name"] != "feature1" {
+		t.Fatalf("unexpected JSON gauge: %+v", used)
+	}
+
+	wait := byName["flexlm_lmutil_wait_seconds"]
+	if wait.Type != "histogram" || len(wait.Metrics) != 1 || wait.Metrics[0].Count != "1" ||
+		wait.Metrics[0].Sum != "0.5" || wait.Metrics[0].Buckets["1"] != "1" {
+		t.Fatalf("unexpected JSON histogram: %+v", wait)
+	}
+
+	if _, err := writeOnce(&out, onceRegistry(1, 0, ""), "yaml"); err == nil {
+		t.Fatal("want error for an unknown format")
+	}
+}
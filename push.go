@@ -0,0 +1,462 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log/slog"
+	"math"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/mjtrangoni/flexlm_exporter/collector"
+	"github.com/mjtrangoni/flexlm_exporter/config"
+	"github.com/prometheus/client_golang/prometheus"
+	promcollectorsversion "github.com/prometheus/client_golang/prometheus/collectors/version"
+	"github.com/prometheus/client_golang/prometheus/push"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/version"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+const (
+	targetPushgateway = "pushgateway"
+	targetRemoteWrite = "remote_write"
+
+	// maxErrorBody is the size of a remote_write error response kept for the
+	// error message.
+	maxErrorBody = 512
+)
+
+// pushConfig is the configuration of the push command.
+type pushConfig struct {
+	pushgatewayURL string
+	remoteWriteURL string
+	job            string
+	interval       time.Duration
+	timeout        time.Duration
+	retries        int
+	retryBackoff   time.Duration
+	bufferSize     int
+}
+
+// permanentError is a remote_write error that retrying doesn't fix, e.g. a
+// rejected sample.
+type permanentError struct {
+	err error
+}
+
+func (e *permanentError) Error() string {
+	return e.err.Error()
+}
+
+func (e *permanentError) Unwrap() error {
+	return e.err
+}
+
+// pusher pushes the gathered metrics. The remote_write batches that couldn't
+// be sent are buffered and sent first on the next push, up to
+// pushConfig.bufferSize batches. The Pushgateway keeps only the latest metrics
+// of a group, so there failed pushes are just replaced by the next ones.
+type pusher struct {
+	cfg      pushConfig
+	instance string
+	client   *http.Client
+	logger   *slog.Logger
+
+	buffer [][]byte
+
+	failures        *prometheus.CounterVec
+	bufferedBatches prometheus.Gauge
+	droppedBatches  prometheus.Counter
+}
+
+func newPusher(cfg pushConfig, logger *slog.Logger) *pusher {
+	instance, err := os.Hostname()
+	if err != nil {
+		logger.Warn("couldn't get hostname for the instance label", "err", err)
+	}
+
+	return &pusher{
+		cfg:      cfg,
+		instance: instance,
+		client:   &http.Client{Timeout: cfg.timeout},
+		logger:   logger,
+		failures: prometheus.NewCounterVec(prometheus.CounterOpts{
+			Namespace: "flexlm",
+			Subsystem: "push",
+			Name:      "failures_total",
+			Help:      "flexlm_exporter: Pushes that failed after all the retries, labeled by target.",
+		}, []string{"target"}),
+		bufferedBatches: prometheus.NewGauge(prometheus.GaugeOpts{
+			Namespace: "flexlm",
+			Subsystem: "push",
+			Name:      "buffered_batches",
+			Help:      "flexlm_exporter: remote_write batches waiting to be sent.",
+		}),
+		droppedBatches: prometheus.NewCounter(prometheus.CounterOpts{
+			Namespace: "flexlm",
+			Subsystem: "push",
+			Name:      "dropped_batches_total",
+			Help:      "flexlm_exporter: remote_write batches dropped because the buffer was full or the endpoint rejected them.",
+		}),
+	}
+}
+
+// runPush runs the enabled collectors on an interval and pushes the metrics
+// until ctx is canceled.
+func runPush(ctx context.Context, cfg pushConfig, configPath string, logger *slog.Logger) error {
+	if cfg.pushgatewayURL == "" && cfg.remoteWriteURL == "" {
+		return errors.New("either --pushgateway-url or --remote-write-url is required")
+	}
+
+	if cfg.interval <= 0 {
+		return fmt.Errorf("invalid push interval: %s", cfg.interval)
+	}
+
+	nc, err := collector.NewFlexlmCollector(logger)
+	if err != nil {
+		return fmt.Errorf("couldn't create collector: %w", err)
+	}
+
+	collector.LicenseConfig, err = config.Load(configPath, logger)
+	if err != nil {
+		return fmt.Errorf("couldn't load config file %s: %w", configPath, err)
+	}
+
+	p := newPusher(cfg, logger)
+
+	r := prometheus.NewRegistry()
+	r.MustRegister(promcollectorsversion.NewCollector("flexlm_exporter"), p.failures, p.bufferedBatches, p.droppedBatches)
+
+	if err := r.Register(nc); err != nil {
+		return fmt.Errorf("couldn't register node collector: %w", err)
+	}
+
+	ticker := time.NewTicker(cfg.interval)
+	defer ticker.Stop()
+
+	for {
+		p.push(ctx, r, time.Now())
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// push gathers the metrics once and pushes them to the configured targets. The
+// retries stop at the next interval, so a slow target doesn't delay the next
+// push.
+func (p *pusher) push(ctx context.Context, g prometheus.Gatherer, now time.Time) {
+	ctx, cancel := context.WithTimeout(ctx, p.cfg.interval)
+	defer cancel()
+
+	mfs, err := g.Gather()
+	if err != nil {
+		p.logger.Warn("couldn't gather all the metrics", "err", err)
+	}
+
+	if p.cfg.pushgatewayURL != "" {
+		p.pushgateway(ctx, mfs)
+	}
+
+	if p.cfg.remoteWriteURL != "" {
+		p.remoteWrite(ctx, mfs, now)
+	}
+}
+
+// retry calls send until it succeeds, returns a permanentError, or fails
+// pushConfig.retries more times, doubling the wait between the calls.
+func (p *pusher) retry(ctx context.Context, target string, send func() error) error {
+	backoff := p.cfg.retryBackoff
+
+	for attempt := 0; ; attempt++ {
+		err := send()
+		if err == nil {
+			return nil
+		}
+
+		var permanent *permanentError
+		if errors.As(err, &permanent) || attempt >= p.cfg.retries {
+			p.failures.WithLabelValues(target).Inc()
+			return err
+		}
+
+		p.logger.Debug("push failed, retrying", "target", target, "attempt", attempt+1, "backoff", backoff, "err", err)
+
+		select {
+		case <-ctx.Done():
+			p.failures.WithLabelValues(target).Inc()
+			return ctx.Err()
+		case <-time.After(backoff):
+		}
+
+		backoff *= 2
+	}
+}
+
+// pushgateway pushes the metrics of each app to its own group, and the other
+// metrics to the group of the job. The groups are pushed concurrently, so that
+// the retries of one don't delay the others.
+func (p *pusher) pushgateway(ctx context.Context, mfs []*dto.MetricFamily) {
+	var wg sync.WaitGroup
+
+	for app, families := range groupByApp(mfs) {
+		pg := push.New(p.cfg.pushgatewayURL, p.cfg.job).
+			Client(p.client).
+			Gatherer(prometheus.GathererFunc(func() ([]*dto.MetricFamily, error) { return families, nil }))
+		if app != "" {
+			pg = pg.Grouping("app", app)
+		}
+
+		wg.Add(1)
+
+		go func() {
+			defer wg.Done()
+
+			if err := p.retry(ctx, targetPushgateway, func() error { return pg.PushContext(ctx) }); err != nil {
+				p.logger.Error("couldn't push to the Pushgateway", "app", app, "err", err)
+			}
+		}()
+	}
+
+	wg.Wait()
+}
+
+// groupByApp splits the metric families by the value of their app label, which
+// is removed since the Pushgateway adds it back from the grouping key. The
+// metrics without app label are grouped under "".
+func groupByApp(mfs []*dto.MetricFamily) map[string][]*dto.MetricFamily {
+	groups := make(map[string][]*dto.MetricFamily)
+
+	for _, mf := range mfs {
+		byApp := make(map[string]*dto.MetricFamily)
+
+		for _, m := range mf.GetMetric() {
+			app := ""
+			labels := make([]*dto.LabelPair, 0, len(m.GetLabel()))
+
+			for _, l := range m.GetLabel() {
+				if l.GetName() == "app" {
+					app = l.GetValue()
+					continue
+				}
+
+				labels = append(labels, l)
+			}
+
+			family, ok := byApp[app]
+			if !ok {
+				family = &dto.MetricFamily{Name: mf.Name, Help: mf.Help, Type: mf.Type, Unit: mf.Unit}
+				byApp[app] = family
+				groups[app] = append(groups[app], family)
+			}
+
+			family.Metric = append(family.Metric, &dto.Metric{
+				Label:       labels,
+				Gauge:       m.Gauge,
+				Counter:     m.Counter,
+				Summary:     m.Summary,
+				Untyped:     m.Untyped,
+				Histogram:   m.Histogram,
+				TimestampMs: m.TimestampMs,
+			})
+		}
+	}
+
+	return groups
+}
+
+// remoteWrite buffers the metrics as a new batch, then sends the buffered
+// batches in order, and stops at the first one that fails.
+func (p *pusher) remoteWrite(ctx context.Context, mfs []*dto.MetricFamily, now time.Time) {
+	batch := s2.EncodeSnappy(nil, encodeWriteRequest(remoteWriteSeries(mfs, p.cfg.job, p.instance), now.UnixMilli()))
+
+	p.buffer = append(p.buffer, batch)
+	if dropped := len(p.buffer) - max(p.cfg.bufferSize, 1); dropped > 0 {
+		p.logger.Warn("remote_write buffer full, dropping the oldest batches", "dropped", dropped)
+		p.droppedBatches.Add(float64(dropped))
+		p.buffer = p.buffer[dropped:]
+	}
+
+	for len(p.buffer) > 0 {
+		err := p.retry(ctx, targetRemoteWrite, func() error { return p.sendRemoteWrite(ctx, p.buffer[0]) })
+
+		var permanent *permanentError
+
+		switch {
+		case err == nil:
+		case errors.As(err, &permanent):
+			p.logger.Error("remote_write endpoint rejected a batch, dropping it", "err", err)
+			p.droppedBatches.Inc()
+		default:
+			p.logger.Error("couldn't send to the remote_write endpoint", "buffered", len(p.buffer), "err", err)
+			p.bufferedBatches.Set(float64(len(p.buffer)))
+
+			return
+		}
+
+		p.buffer = p.buffer[1:]
+	}
+
+	p.bufferedBatches.Set(0)
+}
+
+// sendRemoteWrite sends a snappy compressed WriteRequest. Server errors and
+// rate limits are retried, the other client errors aren't.
+func (p *pusher) sendRemoteWrite(ctx context.Context, batch []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.remoteWriteURL, bytes.NewReader(batch))
+	if err != nil {
+		return &permanentError{err: err}
+	}
+
+	req.Header.Set("Content-Encoding", "snappy")
+	req.Header.Set("Content-Type", "application/x-protobuf")
+	req.Header.Set("User-Agent", "flexlm_exporter/"+version.Version)
+	req.Header.Set("X-Prometheus-Remote-Write-Version", "0.1.0")
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode/100 == 2 {
+		return nil
+	}
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, maxErrorBody))
+	err = fmt.Errorf("unexpected status code %d while sending to %s: %s", resp.StatusCode, p.cfg.remoteWriteURL, body)
+
+	if resp.StatusCode/100 == 5 || resp.StatusCode == http.StatusTooManyRequests {
+		return err
+	}
+
+	return &permanentError{err: err}
+}
+
+// remoteWriteLabel is a label of a remote_write time series.
+type remoteWriteLabel struct {
+	name  string
+	value string
+}
+
+// timeSeries is a remote_write time series with a single sample.
+type timeSeries struct {
+	labels []remoteWriteLabel
+	value  float64
+}
+
+// remoteWriteSeries flattens the metric families into time series, the way
+// Prometheus stores them after a scrape of the job and instance.
+func remoteWriteSeries(mfs []*dto.MetricFamily, job, instance string) []timeSeries {
+	var series []timeSeries
+
+	for _, mf := range mfs {
+		for _, m := range mf.GetMetric() {
+			add := func(name string, value float64, extra ...remoteWriteLabel) {
+				labels := []remoteWriteLabel{{"__name__", name}, {"job", job}, {"instance", instance}}
+				for _, l := range m.GetLabel() {
+					labels = append(labels, remoteWriteLabel{l.GetName(), l.GetValue()})
+				}
+
+				labels = append(labels, extra...)
+				sort.Slice(labels, func(i, j int) bool { return labels[i].name < labels[j].name })
+				series = append(series, timeSeries{labels: labels, value: value})
+			}
+
+			switch mf.GetType() {
+			case dto.MetricType_COUNTER:
+				add(mf.GetName(), m.GetCounter().GetValue())
+			case dto.MetricType_GAUGE:
+				add(mf.GetName(), m.GetGauge().GetValue())
+			case dto.MetricType_UNTYPED:
+				add(mf.GetName(), m.GetUntyped().GetValue())
+			case dto.MetricType_HISTOGRAM, dto.MetricType_GAUGE_HISTOGRAM:
+				h := m.GetHistogram()
+				inf := false
+
+				for _, b := range h.GetBucket() {
+					inf = inf || math.IsInf(b.GetUpperBound(), 1)
+					add(mf.GetName()+"_bucket", float64(b.GetCumulativeCount()),
+						remoteWriteLabel{"le", formatValue(b.GetUpperBound())})
+				}
+
+				if !inf {
+					add(mf.GetName()+"_bucket", float64(h.GetSampleCount()), remoteWriteLabel{"le", "+Inf"})
+				}
+
+				add(mf.GetName()+"_sum", h.GetSampleSum())
+				add(mf.GetName()+"_count", float64(h.GetSampleCount()))
+			case dto.MetricType_SUMMARY:
+				s := m.GetSummary()
+				for _, q := range s.GetQuantile() {
+					add(mf.GetName(), q.GetValue(), remoteWriteLabel{"quantile", formatValue(q.GetQuantile())})
+				}
+
+				add(mf.GetName()+"_sum", s.GetSampleSum())
+				add(mf.GetName()+"_count", float64(s.GetSampleCount()))
+			}
+		}
+	}
+
+	return series
+}
+
+// encodeWriteRequest encodes the time series as a remote_write 1.0
+// WriteRequest protobuf message, all with the same sample timestamp in
+// milliseconds.
+func encodeWriteRequest(series []timeSeries, timestamp int64) []byte {
+	var req []byte
+
+	for _, s := range series {
+		var ts []byte
+
+		for _, l := range s.labels {
+			var label []byte
+			label = protowire.AppendTag(label, 1, protowire.BytesType)
+			label = protowire.AppendString(label, l.name)
+			label = protowire.AppendTag(label, 2, protowire.BytesType)
+			label = protowire.AppendString(label, l.value)
+
+			ts = protowire.AppendTag(ts, 1, protowire.BytesType)
+			ts = protowire.AppendBytes(ts, label)
+		}
+
+		var sample []byte
+		sample = protowire.AppendTag(sample, 1, protowire.Fixed64Type)
+		sample = protowire.AppendFixed64(sample, math.Float64bits(s.value))
+		sample = protowire.AppendTag(sample, 2, protowire.VarintType)
+		sample = protowire.AppendVarint(sample, uint64(timestamp))
+
+		ts = protowire.AppendTag(ts, 2, protowire.BytesType)
+		ts = protowire.AppendBytes(ts, sample)
+
+		req = protowire.AppendTag(req, 1, protowire.BytesType)
+		req = protowire.AppendBytes(req, ts)
+	}
+
+	return req
+}
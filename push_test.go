@@ -0,0 +1,588 @@
+// Copyright 2026 Mario Trangoni
+// Licensed under the Apache License, Version 2.0 (the "License");
+// you may not use this file except in compliance with the License.
+// You may obtain a copy of the License at
+//
+// http://www.apache.org/licenses/LICENSE-2.0
+//
+// Unless required by applicable law or agreed to in writing, software
+// distributed under the License is distributed on an "AS IS" BASIS,
+// WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+// See the License for the specific language governing permissions and
+// limitations under the License.
+
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"net/http"
+	"net/http/httptest"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/klauspost/compress/s2"
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/prometheus/common/expfmt"
+	"github.com/prometheus/common/promslog"
+	"google.golang.org/protobuf/encoding/protowire"
+)
+
+// pushRegistry returns a registry with the feature usage of two apps, and a
+// histogram and a summary without app label.
+func pushRegistry() *prometheus.Registry {
+	used := prometheus.NewGaugeVec(prometheus.GaugeOpts{
+		Name: "flexlm_feature_used",
+		Help: "License feature used.",
+	}, []string{"app", "name"})
+	used.WithLabelValues("app1", "feature1").Set(3)
+	used.WithLabelValues("app2", "feature2").Set(5)
+
+	wait := prometheus.NewHistogram(prometheus.HistogramOpts{
+		Name:    "flexlm_lmutil_wait_seconds",
+		Help:    "flexlm_exporter: Time lmutil calls waited.",
+		Buckets: []float64{1},
+	})
+	wait.Observe(0.5)
+
+	duration := prometheus.NewSummary(prometheus.SummaryOpts{
+		Name:       "flexlm_scrape_duration_seconds",
+		Help:       "flexlm_exporter: Duration of a scrape.",
+		Objectives: map[float64]float64{0.5: 0.05},
+	})
+	duration.Observe(2)
+
+	r := prometheus.NewRegistry()
+	r.MustRegister(used, wait, duration)
+
+	return r
+}
+
+func newTestPusher(cfg pushConfig) *pusher {
+	if cfg.job == "" {
+		cfg.job = "flexlm"
+	}
+
+	if cfg.interval == 0 {
+		cfg.interval = time.Minute
+	}
+
+	if cfg.timeout == 0 {
+		cfg.timeout = 10 * time.Second
+	}
+
+	p := newPusher(cfg, promslog.New(&promslog.Config{}))
+	p.instance = "host1"
+
+	return p
+}
+
+// wireField is a field of a protobuf message.
+type wireField struct {
+	num   protowire.Number
+	bytes []byte
+	value uint64
+}
+
+// wireFields decodes the fields of a protobuf message.
+func wireFields(t *testing.T, b []byte) []wireField {
+	t.Helper()
+
+	var fields []wireField
+
+	for len(b) > 0 {
+		num, typ, n := protowire.ConsumeTag(b)
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+
+		b = b[n:]
+		f := wireField{num: num}
+
+		switch typ {
+		case protowire.BytesType:
+			f.bytes, n = protowire.ConsumeBytes(b)
+		case protowire.Fixed64Type:
+			f.value, n = protowire.ConsumeFixed64(b)
+		case protowire.VarintType:
+			f.value, n = protowire.ConsumeVarint(b)
+		default:
+			t.Fatalf("unexpected wire type %d", typ)
+		}
+
+		if n < 0 {
+			t.Fatal(protowire.ParseError(n))
+		}
+
+		b = b[n:]
+		fields = append(fields, f)
+	}
+
+	return fields
+}
+
+// decodeWriteRequest decodes a snappy compressed WriteRequest into one line
+// per time series, with the labels in the order they were sent, and returns
+// the sample timestamps.
+func decodeWriteRequest(t *testing.T, body []byte) ([]string, []int64) {
+	t.Helper()
+
+	req, err := s2.Decode(nil, body)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var (
+		series     []string
+		timestamps []int64
+	)
+
+	for _, ts := range wireFields(t, req) {
+		if ts.num != 1 {
+			t.Fatalf("unexpected WriteRequest field %d", ts.num)
+		}
+
+		var (
+			labels []string
+			value  float64
+		)
+
+		for _, f := range wireFields(t, ts.bytes) {
+			switch f.num {
+			case 1:
+				label := wireFields(t, f.bytes)
+				if len(label) != 2 || label[0].num != 1 || label[1].num != 2 {
+					t.Fatalf("unexpected label %v", label)
+				}
+
+				labels = append(labels, fmt.Sprintf("%s=%q", label[0].bytes, label[1].bytes))
+			case 2:
+				sample := wireFields(t, f.bytes)
+				if len(sample) != 2 || sample[0].num != 1 || sample[1].num != 2 {
+					t.Fatalf("unexpected sample %v", sample)
+				}
+
+				value = math.Float64frombits(sample[0].value)
+				timestamps = append(timestamps, int64(sample[1].value))
+			default:
+				t.Fatalf("unexpected TimeSeries field %d", f.num)
+			}
+		}
+
+		series = append(series, fmt.Sprintf("{%s} %g", strings.Join(labels, ","), value))
+	}
+
+	return series, timestamps
+}
+
+// statusServer answers the requests with the status codes in turn, and then
+// with the last one, and keeps the request bodies.
+type statusServer struct {
+	mtx      sync.Mutex
+	statuses []int
+	bodies   [][]byte
+	header   http.Header
+}
+
+func (s *statusServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	body, _ := io.ReadAll(r.Body)
+
+	s.mtx.Lock()
+	defer s.mtx.Unlock()
+
+	s.bodies = append(s.bodies, body)
+	s.header = r.Header
+
+	status := s.statuses[0]
+	if len(s.statuses) > 1 {
+		s.statuses = s.statuses[1:]
+	}
+
+	w.WriteHeader(status)
+}
+
+func TestRemoteWrite(t *testing.T) {
+	t.Parallel()
+
+	srv := &statusServer{statuses: []int{http.StatusNoContent}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	p := newTestPusher(pushConfig{remoteWriteURL: ts.URL, bufferSize: 1})
+	now := time.UnixMilli(1700000000123)
+
+	p.push(context.Background(), pushRegistry(), now)
+
+	if len(srv.bodies) != 1 {
+		t.Fatalf("want 1 request, got %d", len(srv.bodies))
+	}
+
+	for name, want := range map[string]string{
+		"Content-Encoding":                  "snappy",
+		"Content-Type":                      "application/x-protobuf",
+		"X-Prometheus-Remote-Write-Version": "0.1.0",
+	} {
+		if got := srv.header.Get(name); got != want {
+			t.Errorf("%s: want %q, got %q", name, want, got)
+		}
+	}
+
+	series, timestamps := decodeWriteRequest(t, srv.bodies[0])
+
+	want := []string{
+		`{__name__="flexlm_feature_used",app="app1",instance="host1",job="flexlm",name="feature1"} 3`,
+		`{__name__="flexlm_feature_used",app="app2",instance="host1",job="flexlm",name="feature2"} 5`,
+		`{__name__="flexlm_lmutil_wait_seconds_bucket",instance="host1",job="flexlm",le="1"} 1`,
+		`{__name__="flexlm_lmutil_wait_seconds_bucket",instance="host1",job="flexlm",le="+Inf"} 1`,
+		`{__name__="flexlm_lmutil_wait_seconds_sum",instance="host1",job="flexlm"} 0.5`,
+		`{__name__="flexlm_lmutil_wait_seconds_count",instance="host1",job="flexlm"} 1`,
+		`{__name__="flexlm_scrape_duration_seconds",instance="host1",job="flexlm",quantile="0.5"} 2`,
+		`{__name__="flexlm_scrape_duration_seconds_sum",instance="host1",job="flexlm"} 2`,
+		`{__name__="flexlm_scrape_duration_seconds_count",instance="host1",job="flexlm"} 1`,
+	}
+	if !slices.Equal(series, want) {
+		t.Fatalf("want series\n%s\ngot\n%s", strings.Join(want, "\n"), strings.Join(series, "\n"))
+	}
+
+	for _, timestamp := range timestamps {
+		if timestamp != now.UnixMilli() {
+			t.Fatalf("want timestamp %d, got %d", now.UnixMilli(), timestamp)
+		}
+	}
+}
+
+func TestRemoteWriteStatus(t *testing.T) {
+	t.Parallel()
+
+	for _, tc := range []struct {
+		name     string
+		statuses []int
+		requests int
+		failures float64
+		buffered float64
+		dropped  float64
+	}{
+		{"success", []int{http.StatusOK}, 1, 0, 0, 0},
+		{"server error retried", []int{http.StatusServiceUnavailable, http.StatusNoContent}, 2, 0, 0, 0},
+		{"rate limit retried", []int{http.StatusTooManyRequests, http.StatusNoContent}, 2, 0, 0, 0},
+		{"server error buffered", []int{http.StatusInternalServerError}, 3, 1, 1, 0},
+		{"client error dropped", []int{http.StatusBadRequest}, 1, 1, 0, 1},
+	} {
+		srv := &statusServer{statuses: tc.statuses}
+		ts := httptest.NewServer(srv)
+
+		p := newTestPusher(pushConfig{remoteWriteURL: ts.URL, retries: 2, retryBackoff: time.Millisecond, bufferSize: 1})
+		p.push(context.Background(), pushRegistry(), time.Now())
+		ts.Close()
+
+		if len(srv.bodies) != tc.requests {
+			t.Errorf("%s: want %d requests, got %d", tc.name, tc.requests, len(srv.bodies))
+		}
+
+		if got := testutil.ToFloat64(p.failures.WithLabelValues(targetRemoteWrite)); got != tc.failures {
+			t.Errorf("%s: want %v failures, got %v", tc.name, tc.failures, got)
+		}
+
+		if got := testutil.ToFloat64(p.bufferedBatches); got != tc.buffered {
+			t.Errorf("%s: want %v buffered batches, got %v", tc.name, tc.buffered, got)
+		}
+
+		if got := testutil.ToFloat64(p.droppedBatches); got != tc.dropped {
+			t.Errorf("%s: want %v dropped batches, got %v", tc.name, tc.dropped, got)
+		}
+	}
+}
+
+func TestRemoteWriteBuffer(t *testing.T) {
+	t.Parallel()
+
+	srv := &statusServer{statuses: []int{http.StatusServiceUnavailable}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	p := newTestPusher(pushConfig{remoteWriteURL: ts.URL, bufferSize: 2})
+	begin := time.UnixMilli(1700000000000)
+
+	for i := range 3 {
+		p.push(context.Background(), pushRegistry(), begin.Add(time.Duration(i)*time.Minute))
+	}
+
+	if got := testutil.ToFloat64(p.bufferedBatches); got != 2 {
+		t.Fatalf("want 2 buffered batches, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(p.droppedBatches); got != 1 {
+		t.Fatalf("want 1 dropped batch, got %v", got)
+	}
+
+	srv.mtx.Lock()
+	srv.statuses = []int{http.StatusNoContent}
+	srv.bodies = nil
+	srv.mtx.Unlock()
+
+	p.push(context.Background(), pushRegistry(), begin.Add(3*time.Minute))
+
+	// The buffer holds the new batch too, so the two oldest were dropped, and
+	// the buffered one is sent first.
+	var got []int64
+
+	for _, body := range srv.bodies {
+		_, timestamps := decodeWriteRequest(t, body)
+		got = append(got, timestamps[0])
+	}
+
+	want := []int64{begin.Add(2 * time.Minute).UnixMilli(), begin.Add(3 * time.Minute).UnixMilli()}
+	if !slices.Equal(got, want) {
+		t.Fatalf("want batches %v, got %v", want, got)
+	}
+
+	if got := testutil.ToFloat64(p.droppedBatches); got != 2 {
+		t.Fatalf("want 2 dropped batches, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(p.bufferedBatches); got != 0 {
+		t.Fatalf("want no buffered batches, got %v", got)
+	}
+}
+
+func TestRetry(t *testing.T) {
+	t.Parallel()
+
+	errSend := errors.New("send failed")
+
+	for _, tc := range []struct {
+		name     string
+		errs     []error
+		calls    int
+		failures float64
+	}{
+		{"success", []error{nil}, 1, 0},
+		{"success after retries", []error{errSend, errSend, nil}, 3, 0},
+		{"retries exhausted", []error{errSend}, 3, 1},
+		{"permanent error", []error{&permanentError{err: errSend}}, 1, 1},
+	} {
+		p := newTestPusher(pushConfig{retries: 2, retryBackoff: time.Millisecond})
+		calls := 0
+
+		err := p.retry(context.Background(), targetRemoteWrite, func() error {
+			err := tc.errs[min(calls, len(tc.errs)-1)]
+			calls++
+
+			return err
+		})
+
+		if (err != nil) != (tc.failures > 0) {
+			t.Errorf("%s: unexpected error %v", tc.name, err)
+		}
+
+		if calls != tc.calls {
+			t.Errorf("%s: want %d calls, got %d", tc.name, tc.calls, calls)
+		}
+
+		if got := testutil.ToFloat64(p.failures.WithLabelValues(targetRemoteWrite)); got != tc.failures {
+			t.Errorf("%s: want %v failures, got %v", tc.name, tc.failures, got)
+		}
+	}
+}
+
+func TestRetryBackoff(t *testing.T) {
+	t.Parallel()
+
+	p := newTestPusher(pushConfig{retries: 3, retryBackoff: 20 * time.Millisecond})
+
+	var calls []time.Time
+
+	begin := time.Now()
+
+	err := p.retry(context.Background(), targetPushgateway, func() error {
+		calls = append(calls, time.Now())
+		return errors.New("send failed")
+	})
+	if err == nil {
+		t.Fatal("want error")
+	}
+
+	if len(calls) != 4 {
+		t.Fatalf("want 4 calls, got %d", len(calls))
+	}
+
+	// The backoff doubles: 20ms, 40ms and 80ms.
+	for i, want := range []time.Duration{20, 60, 140} {
+		if got := calls[i+1].Sub(begin); got < want*time.Millisecond {
+			t.Errorf("call %d: want after %dms, got %s", i+2, want, got)
+		}
+	}
+}
+
+func TestPushDeadline(t *testing.T) {
+	t.Parallel()
+
+	srv := &statusServer{statuses: []int{http.StatusServiceUnavailable}}
+	ts := httptest.NewServer(srv)
+	defer ts.Close()
+
+	p := newTestPusher(pushConfig{
+		remoteWriteURL: ts.URL,
+		interval:       200 * time.Millisecond,
+		retries:        100,
+		retryBackoff:   10 * time.Millisecond,
+		bufferSize:     1,
+	})
+
+	begin := time.Now()
+	p.push(context.Background(), pushRegistry(), begin)
+
+	if elapsed := time.Since(begin); elapsed > 5*time.Second {
+		t.Fatalf("push took %s with an interval of %s", elapsed, p.cfg.interval)
+	}
+
+	if got := testutil.ToFloat64(p.failures.WithLabelValues(targetRemoteWrite)); got != 1 {
+		t.Fatalf("want 1 failure, got %v", got)
+	}
+
+	if got := testutil.ToFloat64(p.bufferedBatches); got != 1 {
+		t.Fatalf("want 1 buffered batch, got %v", got)
+	}
+}
+
+func TestGroupByApp(t *testing.T) {
+	t.Parallel()
+
+	mfs, err := pushRegistry().Gather()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groups := groupByApp(mfs)
+
+	got := make(map[string][]string)
+
+	for app, families := range groups {
+		for _, mf := range families {
+			for _, m := range mf.GetMetric() {
+				var labels []string
+				for _, l := range m.GetLabel() {
+					labels = append(labels, l.GetName()+"="+l.GetValue())
+				}
+
+				got[app] = append(got[app], fmt.Sprintf("%s{%s}", mf.GetName(), strings.Join(labels, ",")))
+			}
+		}
+	}
+
+	want := map[string][]string{
+		"":     {"flexlm_lmutil_wait_seconds{}", "flexlm_scrape_duration_seconds{}"},
+		"app1": {"flexlm_feature_used{name=feature1}"},
+		"app2": {"flexlm_feature_used{name=feature2}"},
+	}
+
+	if len(got) != len(want) {
+		t.Fatalf("want groups %v, got %v", want, got)
+	}
+
+	for app, metrics := range want {
+		if !slices.Equal(got[app], metrics) {
+			t.Errorf("group %q: want %v, got %v", app, metrics, got[app])
+		}
+	}
+
+	// The families of the groups keep their metadata.
+	mf := groups["app1"][0]
+	if mf.GetType() != dto.MetricType_GAUGE || mf.GetHelp() != "License feature used." {
+		t.Errorf("unexpected family %v", mf)
+	}
+}
+
+func TestPushgateway(t *testing.T) {
+	t.Parallel()
+
+	const groups = 3
+
+	var (
+		mtx      sync.Mutex
+		metrics  = make(map[string][]string)
+		arrived  int
+		all      = make(chan struct{})
+		timedOut bool
+	)
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		dec := expfmt.NewDecoder(r.Body, expfmt.ResponseFormat(r.Header))
+
+		var names []string
+
+		for {
+			var mf dto.MetricFamily
+			if err := dec.Decode(&mf); err != nil {
+				break
+			}
+
+			for _, m := range mf.GetMetric() {
+				for _, l := range m.GetLabel() {
+					if l.GetName() == "app" {
+						t.Errorf("%s: app label pushed in %s", r.URL.Path, mf.GetName())
+					}
+				}
+			}
+
+			names = append(names, mf.GetName())
+		}
+
+		mtx.Lock()
+		metrics[r.Method+" "+r.URL.Path] = names
+
+		arrived++
+		if arrived == groups {
+			close(all)
+		}
+		mtx.Unlock()
+
+		// The groups are pushed concurrently, so all of them arrive before
+		// any is answered.
+		select {
+		case <-all:
+		case <-time.After(5 * time.Second):
+			mtx.Lock()
+			timedOut = true
+			mtx.Unlock()
+		}
+
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer ts.Close()
+
+	p := newTestPusher(pushConfig{pushgatewayURL: ts.URL, timeout: 30 * time.Second})
+	p.push(context.Background(), pushRegistry(), time.Now())
+
+	if timedOut {
+		t.Error("the groups weren't pushed concurrently")
+	}
+
+	want := map[string][]string{
+		"PUT /metrics/job/flexlm":          {"flexlm_lmutil_wait_seconds", "flexlm_scrape_duration_seconds"},
+		"PUT /metrics/job/flexlm/app/app1": {"flexlm_feature_used"},
+		"PUT /metrics/job/flexlm/app/app2": {"flexlm_feature_used"},
+	}
+
+	if len(metrics) != len(want) {
+		t.Fatalf("want pushes %v, got %v", want, metrics)
+	}
+
+	for group, names := range want {
+		if !slices.Equal(metrics[group], names) {
+			t.Errorf("%s: want %v, got %v", group, names, metrics[group])
+		}
+	}
+
+	if got := testutil.ToFloat64(p.failures.WithLabelValues(targetPushgateway)); got != 0 {
+		t.Errorf("want no failures, got %v", got)
+	}
+}